@@ -11,11 +11,30 @@ const configFile = "/config.yml"
 const contextSettingsFile = "/context-settings.yml"
 
 type Config struct {
-	RefreshMillis          int    `yaml:"refreshMillis"`
-	CommandExecutor        string `yaml:"commandExecutor"`
-	CommandArgument        string `yaml:"commandArgument"`
-	ForceDockerComposeAnsi bool   `yaml:"forceDockerComposeAnsi"`
-	MaxLogBytes            int    `yaml:"maxLogBytes"`
+	RefreshMillis          int         `yaml:"refreshMillis"`
+	CommandExecutor        string      `yaml:"commandExecutor"`
+	CommandArgument        string      `yaml:"commandArgument"`
+	ForceDockerComposeAnsi bool        `yaml:"forceDockerComposeAnsi"`
+	MaxLogBytes            int         `yaml:"maxLogBytes"`
+	StopTimeout            StopTimeout `yaml:"stopTimeout"`
+}
+
+// StopTimeout holds the seconds after which a stopping process gets the stop signal again or is killed,
+// a negative value such as -1 turns the step off and 0 inherits the global setting
+type StopTimeout struct {
+	Resend int `yaml:"resend"`
+	Kill   int `yaml:"kill"`
+}
+
+// Override replaces the timeouts that are set in override, negative values included
+func (t StopTimeout) Override(override StopTimeout) StopTimeout {
+	if override.Resend != 0 {
+		t.Resend = override.Resend
+	}
+	if override.Kill != 0 {
+		t.Kill = override.Kill
+	}
+	return t
 }
 
 type ContextSettings struct {
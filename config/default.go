@@ -4,5 +4,6 @@ func ApplyDefaults(config *Config) {
 	config.RefreshMillis = 6
 	config.ForceDockerComposeAnsi = true
 	config.MaxLogBytes = 10 * 1024 * 1024
+	config.StopTimeout = StopTimeout{Resend: 10, Kill: 30}
 	applyOsSpecificDefaults(config)
 }
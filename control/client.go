@@ -0,0 +1,73 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+)
+
+// Instances returns the control sockets of the running leggo instances of the current user
+func Instances() []string {
+	paths, _ := filepath.Glob(filepath.Join(socketDir(), socketPrefix()+"*.sock"))
+	var instances []string
+	for _, path := range paths {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			instances = append(instances, path)
+		}
+	}
+	return instances
+}
+
+func dial(path string, request Request) (net.Conn, *json.Decoder, Response, error) {
+	var response Response
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, nil, response, fmt.Errorf("connecting to leggo: %w", err)
+	}
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		conn.Close()
+		return nil, nil, response, fmt.Errorf("sending request: %w", err)
+	}
+	decoder := json.NewDecoder(conn)
+	if err := decoder.Decode(&response); err != nil {
+		conn.Close()
+		return nil, nil, response, fmt.Errorf("reading response: %w", err)
+	}
+	if response.Error != "" {
+		conn.Close()
+		return nil, nil, response, errors.New(response.Error)
+	}
+	return conn, decoder, response, nil
+}
+
+func Send(path string, request Request) (Response, error) {
+	conn, _, response, err := dial(path, request)
+	if err != nil {
+		return response, err
+	}
+	conn.Close()
+	return response, nil
+}
+
+// Logs calls the handler with every log line sent by the server until the server closes the connection
+func Logs(path string, request Request, handler func(line LogLine)) error {
+	request.Command = CommandLogs
+	conn, decoder, _, err := dial(path, request)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		var line LogLine
+		if err := decoder.Decode(&line); err != nil {
+			return nil
+		}
+		if line.Error != "" {
+			return errors.New(line.Error)
+		}
+		handler(line)
+	}
+}
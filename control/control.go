@@ -0,0 +1,65 @@
+package control
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	CommandStatus  = "status"
+	CommandStart   = "start"
+	CommandStop    = "stop"
+	CommandRestart = "restart"
+	CommandLogs    = "logs"
+)
+
+// Request is sent by the client as a single JSON object, the server answers with a Response,
+// followed by a LogLine for every line of the log for the logs command
+type Request struct {
+	Command string `json:"command"`
+	Service string `json:"service,omitempty"`
+	Lines   int    `json:"lines,omitempty"`
+	Follow  bool   `json:"follow,omitempty"`
+}
+
+type Response struct {
+	Error    string          `json:"error,omitempty"`
+	Services []ServiceStatus `json:"services,omitempty"`
+}
+
+type LogLine struct {
+	Line  string `json:"line"`
+	Error string `json:"error,omitempty"`
+}
+
+type ServiceStatus struct {
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	State      string `json:"state"`
+	Pids       []int  `json:"pids"`
+	Unhealthy  bool   `json:"unhealthy"`
+	Failed     bool   `json:"failed"`
+	Restarts   int    `json:"restarts"`
+	Restarting bool   `json:"restarting"`
+	Status     string `json:"status,omitempty"`
+}
+
+// SocketPath returns the path of the control socket of the leggo instance running the context file
+func SocketPath(contextFilePath string) string {
+	hash := sha256.Sum256([]byte(contextFilePath))
+	return filepath.Join(socketDir(), fmt.Sprintf("%s%x.sock", socketPrefix(), hash[:8]))
+}
+
+func socketDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+func socketPrefix() string {
+	return fmt.Sprintf("leggo-%d-", os.Getuid())
+}
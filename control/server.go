@@ -0,0 +1,156 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/andresrobam/leggo/service"
+)
+
+const followBuffer = 4096
+
+type Server struct {
+	listener net.Listener
+	program  service.Sender
+	services []*service.Service
+}
+
+// Listen starts serving the control socket, the actions are sent to the program as messages
+func Listen(path string, program service.Sender, services []*service.Service) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another leggo instance is listening on %s", path)
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	s := &Server{listener: listener, program: program, services: services}
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	encoder := json.NewEncoder(conn)
+	var request Request
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		encoder.Encode(Response{Error: fmt.Sprintf("invalid request: %s", err)})
+		return
+	}
+	if request.Command == CommandStatus {
+		encoder.Encode(Response{Services: Status(s.services)})
+		return
+	}
+	target, ok := Find(s.services, request.Service)
+	if !ok {
+		encoder.Encode(Response{Error: fmt.Sprintf("unknown service: %s", request.Service)})
+		return
+	}
+	switch request.Command {
+	case CommandStart:
+		s.program.Send(service.StartServiceMsg{Service: target.Key})
+	case CommandStop:
+		s.program.Send(service.StopServiceMsg{Service: target.Key})
+	case CommandRestart:
+		s.program.Send(service.RestartServiceMsg{Service: target.Key})
+	case CommandLogs:
+		if encoder.Encode(Response{}) == nil {
+			logs(conn, encoder, target, request)
+		}
+		return
+	default:
+		encoder.Encode(Response{Error: fmt.Sprintf("unknown command: %s", request.Command)})
+		return
+	}
+	encoder.Encode(Response{})
+}
+
+// Find returns the service with the given key from services
+func Find(services []*service.Service, key string) (*service.Service, bool) {
+	i := slices.IndexFunc(services, func(s *service.Service) bool {
+		return s.Key == key
+	})
+	if i == -1 {
+		return nil, false
+	}
+	return services[i], true
+}
+
+// Status returns the state of the services as shown in the header and footer of the terminal UI
+func Status(services []*service.Service) []ServiceStatus {
+	statuses := make([]ServiceStatus, len(services))
+	for i, svc := range services {
+		svc.StateMutex.RLock()
+		statuses[i] = ServiceStatus{
+			Key:        svc.Key,
+			Name:       svc.Name,
+			Type:       svc.Type,
+			State:      svc.State.String(),
+			Pids:       svc.Pids,
+			Unhealthy:  svc.Unhealthy,
+			Failed:     svc.Failed,
+			Restarts:   svc.RestartCount,
+			Restarting: !svc.NextRestart.IsZero() || svc.RestartPending,
+			Status:     svc.StatusText(),
+		}
+		svc.StateMutex.RUnlock()
+	}
+	return statuses
+}
+
+func logs(conn net.Conn, encoder *json.Encoder, target *service.Service, request Request) {
+	if !request.Follow {
+		for _, line := range target.Log.Tail(request.Lines) {
+			if err := encoder.Encode(LogLine{Line: line}); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	lines, overflow, cancel := target.Log.Stream(request.Lines, followBuffer)
+	defer cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+	for {
+		select {
+		case line := <-lines:
+			if err := encoder.Encode(LogLine{Line: line}); err != nil {
+				return
+			}
+		case <-overflow:
+			encoder.Encode(LogLine{Error: "log lines were dropped because the client is too slow"})
+			return
+		case <-closed:
+			return
+		}
+	}
+}
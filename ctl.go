@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andresrobam/leggo/control"
+	"github.com/andresrobam/leggo/service"
+)
+
+const ctlUsage = `Usage: leggo ctl [--context file] [--json] <command> [service]
+
+Commands:
+  status                                      list the services with their state and PIDs
+  start <service>                             start a service
+  stop <service>                              stop a service
+  restart <service>                           restart a service
+  logs [-f] [-n lines] <service>              print the log of a service, -f keeps following it
+  wait-healthy [--timeout seconds] <service>  wait until the service is running and healthy`
+
+const waitHealthyInterval = 250 * time.Millisecond
+
+type ctlOptions struct {
+	context string
+	json    bool
+	follow  bool
+	lines   int
+	timeout int
+	args    []string
+}
+
+func parseCtlArgs(args []string) (ctlOptions, error) {
+	var options ctlOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--json":
+			options.json = true
+			continue
+		case "-f", "--follow":
+			options.follow = true
+			continue
+		case "--context", "-n", "--lines", "--timeout":
+		default:
+			options.args = append(options.args, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return options, fmt.Errorf("missing value for %s", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name == "--context" {
+			options.context = value
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return options, fmt.Errorf("invalid value for %s: %s", name, value)
+		}
+		if name == "--timeout" {
+			options.timeout = number
+		} else {
+			options.lines = number
+		}
+	}
+	return options, nil
+}
+
+func findInstance(contextFile string) (string, error) {
+	if contextFile != "" {
+		absoluteFilePath, err := filepath.Abs(contextFile)
+		if err != nil {
+			return "", err
+		}
+		return control.SocketPath(absoluteFilePath), nil
+	}
+	instances := control.Instances()
+	switch len(instances) {
+	case 0:
+		return "", fmt.Errorf("no running leggo instance found")
+	case 1:
+		return instances[0], nil
+	default:
+		return "", fmt.Errorf("%d leggo instances are running, use --context to pick one", len(instances))
+	}
+}
+
+func runCtl(args []string) int {
+	options, err := parseCtlArgs(args)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(ctlUsage)
+		return 1
+	}
+	if len(options.args) == 0 {
+		fmt.Println(ctlUsage)
+		return 1
+	}
+	command := options.args[0]
+	var serviceKey string
+	if command != control.CommandStatus {
+		if len(options.args) != 2 {
+			fmt.Println(ctlUsage)
+			return 1
+		}
+		serviceKey = options.args[1]
+	}
+	path, err := findInstance(options.context)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	switch command {
+	case control.CommandStatus:
+		err = ctlStatus(path, options.json)
+	case control.CommandStart, control.CommandStop, control.CommandRestart:
+		var response control.Response
+		response, err = control.Send(path, control.Request{Command: command, Service: serviceKey})
+		if err == nil && options.json {
+			printJson(response)
+		}
+	case control.CommandLogs:
+		err = control.Logs(path, control.Request{Service: serviceKey, Lines: options.lines, Follow: options.follow}, func(line control.LogLine) {
+			if options.json {
+				printJson(line)
+			} else {
+				fmt.Println(line.Line)
+			}
+		})
+	case "wait-healthy":
+		err = ctlWaitHealthy(path, serviceKey, options.timeout)
+	default:
+		fmt.Println(ctlUsage)
+		return 1
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func printJson(value any) {
+	data, _ := json.Marshal(value)
+	fmt.Println(string(data))
+}
+
+func ctlStatus(path string, asJson bool) error {
+	response, err := control.Send(path, control.Request{Command: control.CommandStatus})
+	if err != nil {
+		return err
+	}
+	if asJson {
+		printJson(response.Services)
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVICE\tTYPE\tSTATE\tPIDS\tRESTARTS\tSTATUS")
+	for _, s := range response.Services {
+		pids := make([]string, len(s.Pids))
+		for i, pid := range s.Pids {
+			pids[i] = strconv.Itoa(pid)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Key, s.Type, s.State, strings.Join(pids, ","), s.Restarts, s.Status)
+	}
+	return writer.Flush()
+}
+
+func ctlWaitHealthy(path string, serviceKey string, timeout int) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+	}
+	for {
+		response, err := control.Send(path, control.Request{Command: control.CommandStatus})
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, s := range response.Services {
+			if s.Key != serviceKey {
+				continue
+			}
+			found = true
+			if (s.State == service.StateRunning.String() && !s.Unhealthy) || s.State == service.StateDone.String() {
+				return nil
+			}
+			if s.State == service.StateStopped.String() && s.Failed && !s.Restarting {
+				return fmt.Errorf("%s failed and won't be restarted", serviceKey)
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown service: %s", serviceKey)
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("%s is not healthy after %ds", serviceKey, timeout)
+		}
+		time.Sleep(waitHealthyInterval)
+	}
+}
@@ -0,0 +1,151 @@
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/andresrobam/leggo/control"
+	"github.com/andresrobam/leggo/service"
+	"github.com/charmbracelet/x/ansi"
+)
+
+//go:embed index.html
+var indexHtml []byte
+
+const streamBuffer = 4096
+
+// streamedLines matches the number of lines the page keeps
+const streamedLines = 10000
+
+const readHeaderTimeout = 10 * time.Second
+
+type dashboard struct {
+	name     string
+	program  service.Sender
+	services []*service.Service
+	hosts    []string
+}
+
+// Serve starts the web dashboard on the address, the start and stop buttons send messages to the program
+func Serve(address string, name string, program service.Sender, services []*service.Service) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	d := &dashboard{
+		name:     name,
+		program:  program,
+		services: services,
+		hosts:    []string{address, net.JoinHostPort("localhost", port), net.JoinHostPort("127.0.0.1", port), net.JoinHostPort("::1", port)},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.index)
+	mux.HandleFunc("GET /api/services", d.status)
+	mux.HandleFunc("GET /api/services/{service}/logs", d.logs)
+	mux.HandleFunc("POST /api/services/{service}/{action}", d.action)
+	server := &http.Server{Handler: d.checkHost(mux), ReadHeaderTimeout: readHeaderTimeout}
+	go server.Serve(listener)
+	return server, nil
+}
+
+// checkHost only lets through requests made to the listen address or localhost,
+// so a page on another domain resolving to this machine can't use the dashboard
+func (d *dashboard) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(d.hosts, r.Host) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || !slices.Contains(d.hosts, u.Host) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *dashboard) index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHtml)
+}
+
+func (d *dashboard) status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Name     string                  `json:"name"`
+		Services []control.ServiceStatus `json:"services"`
+	}{d.name, control.Status(d.services)})
+}
+
+func (d *dashboard) logs(w http.ResponseWriter, r *http.Request) {
+	s, ok := control.Find(d.services, r.PathValue("service"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	lines, overflow, cancel := s.Log.Stream(streamedLines, streamBuffer)
+	defer cancel()
+	for {
+		select {
+		case line := <-lines:
+			data, _ := json.Marshal(ansi.Strip(line))
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			if len(lines) == 0 {
+				flusher.Flush()
+			}
+		case <-overflow:
+			// the browser reconnects on its own and gets the whole log again
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (d *dashboard) action(w http.ResponseWriter, r *http.Request) {
+	// a custom header can't be sent cross-origin without a preflight, so other sites can't post forms here
+	if r.Header.Get("X-Leggo") == "" {
+		http.Error(w, "missing X-Leggo header", http.StatusForbidden)
+		return
+	}
+	s, ok := control.Find(d.services, r.PathValue("service"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.PathValue("action") {
+	case "start":
+		d.program.Send(service.StartServiceMsg{Service: s.Key})
+	case "stop":
+		d.program.Send(service.StopServiceMsg{Service: s.Key})
+	case "restart":
+		d.program.Send(service.RestartServiceMsg{Service: s.Key})
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Source struct {
+	Dir     string
+	Env     map[string]string
+	EnvFile []string
+}
+
+func Resolve(sources ...Source) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, source := range sources {
+		for _, envFile := range source.EnvFile {
+			if !filepath.IsAbs(envFile) {
+				envFile = filepath.Join(source.Dir, envFile)
+			}
+			fileVars, err := ReadFile(envFile)
+			if err != nil {
+				return nil, err
+			}
+			maps.Copy(vars, fileVars)
+		}
+		maps.Copy(vars, source.Env)
+	}
+	return vars, nil
+}
+
+func Environ(vars map[string]string) []string {
+	if len(vars) == 0 {
+		return nil
+	}
+	environ := os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(vars)) {
+		environ = append(environ, key+"="+vars[key])
+	}
+	return environ
+}
+
+func ReadFile(fileName string) (map[string]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return vars, nil
+}
+
+func Parse(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+func parseValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch value[0] {
+	case '"':
+		end := closingQuote(value)
+		if end == -1 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return strconv.Unquote(value[:end+1])
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return value[1 : end+1], nil
+	}
+	if comment := strings.Index(value, " #"); comment != -1 {
+		value = value[:comment]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/andresrobam/leggo/service"
+)
+
+type group struct {
+	Name     string
+	Services []string
+}
+
+type startGroupMsg struct {
+	Group string
+}
+
+var groups []group
+var groupPicker bool
+var groupPickerIndex int
+
+func findGroup(name string) *group {
+	for i := range groups {
+		if groups[i].Name == name {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+func startGroup(g *group) {
+	if quitting {
+		return
+	}
+	for _, serviceKey := range g.Services {
+		s := service.Services[serviceKey]
+		s.StateMutex.Lock()
+		if s.State == service.StateStopped || s.State == service.StateDone {
+			s.StartService()
+		}
+		s.StateMutex.Unlock()
+	}
+}
+
+func stopGroup(g *group) {
+	for _, serviceKey := range slices.Backward(g.Services) {
+		s := service.Services[serviceKey]
+		s.StateMutex.Lock()
+		s.CancelRestart()
+		if s.State == service.StateStarting || s.State == service.StateRunning {
+			s.Stop()
+		}
+		s.StateMutex.Unlock()
+	}
+}
+
+func openGroupPicker() {
+	if len(groups) == 0 {
+		popup = "No groups defined in " + context.Name
+		return
+	}
+	groupPicker = true
+	groupPickerIndex = min(groupPickerIndex, len(groups)-1)
+	popup = groupPickerView()
+}
+
+func closeGroupPicker() {
+	groupPicker = false
+	popup = ""
+}
+
+func handleGroupPickerKey(k string) {
+	switch k {
+	case "up", "k":
+		groupPickerIndex = (groupPickerIndex + len(groups) - 1) % len(groups)
+	case "down", "j":
+		groupPickerIndex = (groupPickerIndex + 1) % len(groups)
+	case "enter", "space":
+		startGroup(&groups[groupPickerIndex])
+		closeGroupPicker()
+		return
+	case "s":
+		stopGroup(&groups[groupPickerIndex])
+		closeGroupPicker()
+		return
+	case "esc", "q", "g":
+		closeGroupPicker()
+		return
+	}
+	popup = groupPickerView()
+}
+
+func groupPickerView() string {
+	lines := []string{"Groups:", ""}
+	for i, g := range groups {
+		var running int
+		for _, serviceKey := range g.Services {
+			if state := service.Services[serviceKey].GetState(); state == service.StateRunning || state == service.StateDone {
+				running++
+			}
+		}
+		cursor := "  "
+		if i == groupPickerIndex {
+			cursor = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%s (%d/%d running): %s", cursor, g.Name, running, len(g.Services), strings.Join(g.Services, ", ")))
+	}
+	lines = append(lines, "", "[enter] to start, [s] to stop, [esc] to close")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/andresrobam/leggo/control"
+	"github.com/andresrobam/leggo/service"
+	"github.com/charmbracelet/x/term"
+)
+
+// headlessSender stands in for the bubbletea program when running without the TUI,
+// the messages sent by the services are handled in runHeadless
+type headlessSender struct {
+	msgs chan tea.Msg
+}
+
+func (h *headlessSender) Send(msg tea.Msg) {
+	h.msgs <- msg
+}
+
+type idleCheckMsg struct{}
+
+// idleCheckDelay leaves time for the messages sent right after a service stops to arrive,
+// e.g. the start message of a restart, before deciding that nothing is running anymore
+const idleCheckDelay = 200 * time.Millisecond
+
+var prefixColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+const maxPendingLines = 100000
+
+// headlessOutput collects the log lines of every service in order and writes them to stdout
+// outside of the log handlers, so a slow stdout doesn't hold up writing the logs
+type headlessOutput struct {
+	mutex      sync.Mutex
+	lines      []string
+	dropped    int
+	ready      chan struct{}
+	writeMutex sync.Mutex
+}
+
+func (o *headlessOutput) add(line string) {
+	o.mutex.Lock()
+	if len(o.lines) < maxPendingLines {
+		o.lines = append(o.lines, line)
+	} else {
+		o.dropped++
+	}
+	o.mutex.Unlock()
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (o *headlessOutput) write() {
+	o.writeMutex.Lock()
+	defer o.writeMutex.Unlock()
+	o.mutex.Lock()
+	lines, dropped := o.lines, o.dropped
+	o.lines, o.dropped = nil, 0
+	o.mutex.Unlock()
+	writer := bufio.NewWriter(os.Stdout)
+	for _, line := range lines {
+		writer.WriteString(line + "\n")
+	}
+	if dropped != 0 {
+		fmt.Fprintf(writer, "%d log lines were dropped because stdout is too slow\n", dropped)
+	}
+	writer.Flush()
+}
+
+func (o *headlessOutput) run() {
+	for range o.ready {
+		o.write()
+	}
+}
+
+func useColors() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalSize returns the size of the terminal on stdout, or 80x24 when stdout isn't a terminal
+func terminalSize() (int, int) {
+	if width, height, err := term.GetSize(os.Stdout.Fd()); err == nil && width > 0 && height > 0 {
+		return width, height
+	}
+	return 80, 24
+}
+
+func printLines(services []*service.Service) *headlessOutput {
+	colors := useColors()
+	var width int
+	for _, s := range services {
+		width = max(width, len(s.Key)+2)
+	}
+	terminalWidth, terminalHeight := terminalSize()
+	for _, s := range services {
+		s.Log.SetSize(max(terminalWidth-width-1, 20), terminalHeight)
+	}
+	output := &headlessOutput{ready: make(chan struct{}, 1)}
+	go output.run()
+	for i, s := range services {
+		prefix := fmt.Sprintf("%-*s", width, "["+s.Key+"]")
+		if colors {
+			prefix = fmt.Sprintf("\x1b[%sm%s\x1b[0m", prefixColors[i%len(prefixColors)], prefix)
+		}
+		s.Log.Subscribe(func(line string) {
+			output.add(prefix + " " + line)
+		})
+	}
+	return output
+}
+
+func anyActive() bool {
+	for i := range services {
+		services[i].StateMutex.RLock()
+		active := (services[i].State != service.StateStopped && services[i].State != service.StateDone) ||
+			services[i].RestartPending || !services[i].NextRestart.IsZero() || len(services[i].ResumeAfter) != 0
+		services[i].StateMutex.RUnlock()
+		if active {
+			return true
+		}
+	}
+	return false
+}
+
+func headlessExitCode() int {
+	for i := range services {
+		services[i].StateMutex.RLock()
+		failed := services[i].Failed
+		services[i].StateMutex.RUnlock()
+		if failed {
+			return 1
+		}
+	}
+	return 0
+}
+
+func runHeadless() int {
+	msgs := startupMsgs()
+	if len(msgs) == 0 {
+		fmt.Println("Nothing to start, use --start, --group or autostart with --headless")
+		return 1
+	}
+
+	sender := &headlessSender{msgs: make(chan tea.Msg, 100)}
+	controlServer, err := control.Listen(control.SocketPath(context.FilePath), sender, slices.Clone(services))
+	if err != nil {
+		fmt.Println("Control socket not available:", err)
+	} else {
+		defer controlServer.Close()
+	}
+	if dashboardServer := startDashboard(sender); dashboardServer != nil {
+		defer dashboardServer.Close()
+		fmt.Printf("Dashboard listening on http://%s\n", httpAddress)
+	}
+	output := printLines(services)
+	defer output.write()
+	for i := range services {
+		services[i].Program = sender
+		if services[i].Watch != nil {
+			go services[i].WatchFiles()
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	for _, msg := range msgs {
+		handleServiceMsg(msg)
+	}
+
+	for {
+		select {
+		case <-signals:
+			if !quitting {
+				if stopAllServices(true) {
+					return headlessExitCode()
+				}
+				continue
+			}
+			for i := range services {
+				services[i].StateMutex.Lock()
+				if services[i].State != service.StateStopped && services[i].State != service.StateDone {
+					services[i].EndService()
+				}
+				services[i].StateMutex.Unlock()
+			}
+		case msg := <-sender.msgs:
+			switch msg.(type) {
+			case idleCheckMsg:
+				if !quitting && !anyActive() {
+					return headlessExitCode()
+				}
+			case service.ServiceStoppedMsg, service.ServiceStartedMsg:
+				time.AfterFunc(idleCheckDelay, func() {
+					sender.Send(idleCheckMsg{})
+				})
+			}
+			if handleServiceMsg(msg) {
+				return headlessExitCode()
+			}
+		}
+	}
+}
@@ -40,6 +40,10 @@ type Log struct {
 	view                        string
 	size                        int
 	mode                        Mode
+	stdin                       func(input string) error
+	stdinErrorMessage           string
+	subscribers                 map[int]func(line string)
+	nextSubscriberId            int
 }
 
 type Mode int
@@ -50,6 +54,8 @@ const (
 	ModeSearchNavigation
 	ModeFilterInput
 	ModeFiltered
+	ModeStdinInput
+	ModeRawStdinInput
 )
 
 type InputMode int
@@ -163,6 +169,10 @@ func (l *Log) GetHeight() int {
 	return l.height
 }
 
+func (l *Log) GetWidth() int {
+	return l.width
+}
+
 func (l *Log) HandleKey(msg tea.KeyPressMsg) (bool, tea.Cmd) {
 	k := msg.String()
 	if k == "ctrl+c" {
@@ -178,7 +188,35 @@ func (l *Log) HandleKey(msg tea.KeyPressMsg) (bool, tea.Cmd) {
 		} else if k == "f" {
 			l.setMode(ModeFilterInput)
 			return true, nil
+		} else if k == "i" && l.stdin != nil {
+			l.setMode(ModeStdinInput)
+			return true, nil
+		} else if k == "I" && l.stdin != nil {
+			l.setMode(ModeRawStdinInput)
+			return true, nil
 		}
+	case ModeStdinInput:
+		if l.handleScroll(msg, true) {
+			return true, nil
+		} else if k == "esc" {
+			l.setMode(ModeNormal)
+			return true, nil
+		} else if k == "enter" {
+			l.sendStdin(l.input.Value() + "\n")
+			l.input.SetValue("")
+			return true, nil
+		} else {
+			var cmd tea.Cmd
+			l.input, cmd = l.input.Update(msg)
+			return true, cmd
+		}
+	case ModeRawStdinInput:
+		if k == "esc" {
+			l.setMode(ModeNormal)
+		} else if input := keyInput(msg); input != "" {
+			l.sendStdin(input)
+		}
+		return true, nil
 	case ModeSearchInput:
 		if l.handleScroll(msg, true) {
 			return true, nil
@@ -352,12 +390,20 @@ func (l *Log) setMode(mode Mode) {
 	l.mode = mode
 
 	switch mode {
-	case ModeFilterInput, ModeSearchInput:
+	case ModeFilterInput, ModeSearchInput, ModeStdinInput:
 		l.input.Focus()
 	default:
 		l.input.Blur()
 	}
 
+	switch mode {
+	case ModeStdinInput:
+		l.input.SetValue("")
+		l.stdinErrorMessage = ""
+	case ModeRawStdinInput:
+		l.stdinErrorMessage = ""
+	}
+
 	switch mode {
 	case ModeFilterInput:
 		l.input.SetValue("")
@@ -391,7 +437,7 @@ func (l *Log) setMode(mode Mode) {
 }
 
 func (l *Log) HandleNonKeyMsg(msg tea.Msg) (cmd tea.Cmd) {
-	if l.mode != ModeFilterInput && l.mode != ModeSearchInput {
+	if l.mode != ModeFilterInput && l.mode != ModeSearchInput && l.mode != ModeStdinInput {
 		return nil
 	}
 	l.input, cmd = l.input.Update(msg)
@@ -699,13 +745,28 @@ func (l *Log) InputView() string { // TODO: make it pretty
 		mode = "Filter"
 	case ModeSearchInput, ModeSearchNavigation:
 		mode = "Search"
+	case ModeStdinInput:
+		mode = "Input"
+	case ModeRawStdinInput:
+		return "Raw input | keys are sent to the process, [esc] to exit" + l.stdinErrorView()
 	}
 
 	return mode + l.input.View() + " | " + l.inputViewRightSide()
 }
 
+func (l *Log) stdinErrorView() string {
+	if l.stdinErrorMessage == "" {
+		return ""
+	}
+	return " | " + l.stdinErrorMessage
+}
+
 func (l *Log) inputViewRightSide() string {
 
+	if l.mode == ModeStdinInput {
+		return "[enter] to send, [esc] to exit" + l.stdinErrorView()
+	}
+
 	var errorMessage string
 
 	switch l.mode {
@@ -863,6 +924,9 @@ func (l *Log) AddContent(addition string, endLine bool) {
 			l.searchResultIndex = len(l.searchResults) - 1
 		}
 	}
+	if endLine && len(l.subscribers) != 0 {
+		l.notifySubscribers(l.lines[len(l.lines)-1])
+	}
 	l.clearOldLines()
 	l.contentUpdated.Store(true)
 }
@@ -0,0 +1,50 @@
+package log
+
+import (
+	tea "charm.land/bubbletea/v2"
+)
+
+func (l *Log) SetStdinHandler(handler func(input string) error) {
+	l.contentMutex.Lock()
+	defer l.contentMutex.Unlock()
+	l.stdin = handler
+}
+
+func (l *Log) sendStdin(input string) {
+	if err := l.stdin(input); err != nil {
+		l.stdinErrorMessage = err.Error()
+	} else {
+		l.stdinErrorMessage = ""
+	}
+	l.contentUpdated.Store(true)
+}
+
+func keyInput(msg tea.KeyPressMsg) string {
+	key := msg.Key()
+	if key.Mod == tea.ModCtrl && key.Code >= 'a' && key.Code <= 'z' {
+		return string(rune(key.Code - 'a' + 1))
+	}
+	switch key.Code {
+	case tea.KeyEnter:
+		return "\n"
+	case tea.KeyTab:
+		return "\t"
+	case tea.KeyBackspace:
+		return "\x7f"
+	case tea.KeyUp:
+		return "\x1b[A"
+	case tea.KeyDown:
+		return "\x1b[B"
+	case tea.KeyRight:
+		return "\x1b[C"
+	case tea.KeyLeft:
+		return "\x1b[D"
+	case tea.KeyHome:
+		return "\x1b[H"
+	case tea.KeyEnd:
+		return "\x1b[F"
+	case tea.KeyDelete:
+		return "\x1b[3~"
+	}
+	return key.Text
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"slices"
+	"sync"
+)
+
+// Subscribe calls the handler with every line of the log once the line is complete,
+// the handler is called with the log locked so it must not block.
+// The returned function cancels the subscription
+func (l *Log) Subscribe(handler func(line string)) func() {
+	l.contentMutex.Lock()
+	defer l.contentMutex.Unlock()
+	return l.subscribe(handler)
+}
+
+func (l *Log) subscribe(handler func(line string)) func() {
+	if l.subscribers == nil {
+		l.subscribers = make(map[int]func(line string))
+	}
+	id := l.nextSubscriberId
+	l.nextSubscriberId++
+	l.subscribers[id] = handler
+	return func() {
+		l.contentMutex.Lock()
+		defer l.contentMutex.Unlock()
+		delete(l.subscribers, id)
+	}
+}
+
+func (l *Log) notifySubscribers(line string) {
+	for _, handler := range l.subscribers {
+		handler(line)
+	}
+}
+
+// Tail returns the last n complete lines of the log, every line when n is 0 or less
+func (l *Log) Tail(n int) []string {
+	l.contentMutex.RLock()
+	defer l.contentMutex.RUnlock()
+	return l.tail(n)
+}
+
+func (l *Log) tail(n int) []string {
+	lines := l.lines
+	if l.lastLineOpen {
+		lines = lines[:len(lines)-1]
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return slices.Clone(lines)
+}
+
+// Stream sends the last n complete lines of the log and every new one on a channel so the reader is free to block,
+// the overflow channel is closed when the reader falls more than buffer new lines behind
+func (l *Log) Stream(n int, buffer int) (<-chan string, <-chan struct{}, func()) {
+	l.contentMutex.Lock()
+	defer l.contentMutex.Unlock()
+	tail := l.tail(n)
+	lines := make(chan string, len(tail)+buffer)
+	for _, line := range tail {
+		lines <- line
+	}
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	cancel := l.subscribe(func(line string) {
+		select {
+		case lines <- line:
+		default:
+			overflowOnce.Do(func() {
+				close(overflow)
+			})
+		}
+	})
+	return lines, overflow, cancel
+}
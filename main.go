@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"image/color"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +16,9 @@ import (
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
 	"github.com/andresrobam/leggo/config"
+	"github.com/andresrobam/leggo/control"
+	"github.com/andresrobam/leggo/dashboard"
+	"github.com/andresrobam/leggo/env"
 	"github.com/andresrobam/leggo/lock"
 	"github.com/andresrobam/leggo/log"
 	"github.com/andresrobam/leggo/service"
@@ -26,7 +32,24 @@ type model struct {
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	var cmds []tea.Cmd
+	for _, msg := range startupMsgs() {
+		cmds = append(cmds, func() tea.Msg {
+			return msg
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+func startupMsgs() []tea.Msg {
+	var msgs []tea.Msg
+	if startupGroup != "" {
+		msgs = append(msgs, startGroupMsg{Group: startupGroup})
+	}
+	for _, serviceKey := range startupServices {
+		msgs = append(msgs, service.StartServiceMsg{Service: serviceKey})
+	}
+	return msgs
 }
 
 func saveContextSettings() {
@@ -79,7 +102,7 @@ func visibleServiceIndexes() []int {
 
 	for i := range services {
 		services[i].StateMutex.RLock()
-		if !onlyActive || services[i].State != service.StateStopped || i == activeIndex {
+		if !onlyActive || (services[i].State != service.StateStopped && services[i].State != service.StateDone) || i == activeIndex {
 			visibleServiceIndexes = append(visibleServiceIndexes, i)
 		}
 		services[i].StateMutex.RUnlock()
@@ -158,6 +181,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				activeLog.AddContent(info, true)
 			}
 		}
+		if groupPicker && k != "ctrl+c" {
+			handleGroupPickerKey(k)
+			break
+		}
+		if processPicker && k != "ctrl+c" {
+			handleProcessPickerKey(k)
+			break
+		}
 		var keyConsumed bool
 		keyConsumed, cmd = activeLog.HandleKey(msg)
 		if keyConsumed {
@@ -174,6 +205,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 			showHelp = false
+			groupPicker = false
+			processPicker = false
 			if stopAllServices(true) {
 				return m, tea.Quit
 			}
@@ -191,19 +224,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				activeMutex.RLock()
 				activeService.StateMutex.Lock()
 				switch activeService.State {
-				case service.StateStopped:
+				case service.StateStopped, service.StateDone:
 					if !quitting {
 						activeService.StartService()
 					}
 				case service.StateStarting:
-					activeService.EndService()
+					activeService.Stop()
 				case service.StateRunning:
-					activeService.EndService()
+					activeService.Stop()
 				case service.StateStopping:
 					activeService.EndService()
 				}
 				activeService.StateMutex.Unlock()
 				activeMutex.RUnlock()
+			} else if k == "r" || k == "R" {
+				activeMutex.RLock()
+				activeService.StateMutex.Lock()
+				if !quitting {
+					if k == "r" {
+						activeService.Restart()
+					} else {
+						activeService.RestartWithDependents()
+					}
+				}
+				activeService.StateMutex.Unlock()
+				activeMutex.RUnlock()
 			} else if k == "left" || k == "h" {
 				changeActive(false)
 			} else if k == "right" || k == "l" {
@@ -214,17 +259,68 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				swap(1)
 			} else if k == "a" {
 				onlyActive = !onlyActive
+			} else if k == "g" {
+				openGroupPicker()
+			} else if k == "m" {
+				openUsagePopup()
+			} else if k == "p" {
+				activeMutex.RLock()
+				openProcessPicker(activeService)
+				activeMutex.RUnlock()
+			} else if k == "e" {
+				activeMutex.RLock()
+				popup = environmentPopup(activeService)
+				activeMutex.RUnlock()
 			} else if msg.Key().Code == '?' {
 				showHelp = true
 			}
 		}
 
+	case service.ServiceStoppedMsg, service.ServiceStartedMsg, service.StartServiceMsg, service.StopServiceMsg,
+		service.RestartServiceMsg, startGroupMsg, service.RestartBackoffMsg, service.FileChangedMsg, lock.LockReleaseMsg:
+		if handleServiceMsg(msg) {
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		activeMutex.RLock()
+		headerHeight := lipgloss.Height(m.headerView(msg.Width))
+		footerHeight := lipgloss.Height(m.footerView(msg.Width))
+		m.height = msg.Height
+		m.width = msg.Width
+
+		setLogSizes(msg.Width, msg.Height, headerHeight, footerHeight)
+
+		if !m.ready {
+			m.ready = true
+		}
+
+		activeMutex.RUnlock()
+	default:
+		if _, ok := msg.(service.ContentUpdateMsg); ok {
+			if processPicker && time.Since(processPickerRefreshedAt) >= processPickerRefreshInterval {
+				refreshProcessPicker()
+			}
+			if groupPicker {
+				popup = groupPickerView()
+			}
+			refreshUsagePopup()
+		}
+		cmd = activeLog.HandleNonKeyMsg(msg)
+	}
+
+	return m, cmd
+}
+
+// handleServiceMsg handles the messages sent by services, it returns true when the application should exit
+func handleServiceMsg(msg tea.Msg) bool {
+	switch msg := msg.(type) {
 	case service.ServiceStoppedMsg:
 		if quitting {
 			var anyRunning bool
 			for i := range services {
 				services[i].StateMutex.RLock()
-				if services[i].State != service.StateStopped {
+				if services[i].State != service.StateStopped && services[i].State != service.StateDone {
 					anyRunning = true
 					services[i].StateMutex.RUnlock()
 					break
@@ -232,12 +328,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				services[i].StateMutex.RUnlock()
 			}
 			if !anyRunning {
-				return m, tea.Quit
+				return true
 			}
 		} else {
+			for i := range services {
+				services[i].DependencyStopped(msg.Service)
+				services[i].DependentStopped(msg.Service)
+			}
 			for i := range services {
 				if slices.Contains(services[i].WaitList, msg.Service) {
-					startService(msg.Service)
+					s := service.Services[msg.Service]
+					s.StateMutex.Lock()
+					started := s.StartForWaiters()
+					s.StateMutex.Unlock()
+					if !started {
+						for j := range services {
+							services[j].DependencyFailed(msg.Service)
+						}
+					}
 					break
 				}
 			}
@@ -246,35 +354,74 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case service.ServiceStartedMsg:
 		for i := range services {
 			services[i].DoneWaiting(msg.Service)
+			if !quitting {
+				services[i].DependencyStarted(msg.Service)
+			}
 		}
 
 	case service.StartServiceMsg:
 		startService(msg.Service)
 
-	case lock.LockReleaseMsg:
-		for i := range services {
-			services[i].HandleUnlock(msg.Locks)
+	case service.StopServiceMsg:
+		s := service.Services[msg.Service]
+		s.StateMutex.Lock()
+		s.CancelRestart()
+		if s.State == service.StateStarting || s.State == service.StateRunning {
+			s.Stop()
+		}
+		s.StateMutex.Unlock()
+
+	case service.RestartServiceMsg:
+		if !quitting {
+			s := service.Services[msg.Service]
+			s.StateMutex.Lock()
+			s.Restart()
+			s.StateMutex.Unlock()
 		}
 
-	case tea.WindowSizeMsg:
-		activeMutex.RLock()
-		headerHeight := lipgloss.Height(m.headerView(msg.Width))
-		footerHeight := lipgloss.Height(m.footerView(msg.Width))
-		m.height = msg.Height
-		m.width = msg.Width
+	case startGroupMsg:
+		startGroup(findGroup(msg.Group))
 
-		setLogSizes(msg.Width, msg.Height, headerHeight, footerHeight)
+	case service.RestartBackoffMsg:
+		if !quitting {
+			s := service.Services[msg.Service]
+			s.StateMutex.Lock()
+			s.RestartAfterBackoff()
+			s.StateMutex.Unlock()
+		}
 
-		if !m.ready {
-			m.ready = true
+	case service.FileChangedMsg:
+		if !quitting {
+			s := service.Services[msg.Service]
+			s.StateMutex.Lock()
+			s.RestartForChange(msg.Files)
+			s.StateMutex.Unlock()
 		}
 
-		activeMutex.RUnlock()
-	default:
-		cmd = activeLog.HandleNonKeyMsg(msg)
+	case lock.LockReleaseMsg:
+		for i := range services {
+			services[i].HandleUnlock(msg.Locks)
+		}
 	}
+	return false
+}
 
-	return m, cmd
+func environmentPopup(s *service.Service) string {
+	s.StateMutex.RLock()
+	c := s.Commands[s.ActiveCommandIndex]
+	s.StateMutex.RUnlock()
+	vars, err := s.Environment(&c)
+	if err != nil {
+		return "Error loading environment of " + s.Name + ":\n" + err.Error()
+	}
+	if len(vars) == 0 {
+		return "No environment variables defined for " + s.Name
+	}
+	lines := []string{"Environment of " + s.Name + ":", ""}
+	for _, key := range slices.Sorted(maps.Keys(vars)) {
+		lines = append(lines, key+"="+vars[key])
+	}
+	return strings.Join(lines, "\n")
 }
 
 func setLogSizes(width int, height int, headerHeight int, footerHeight int) {
@@ -285,6 +432,7 @@ func setLogSizes(width int, height int, headerHeight int, footerHeight int) {
 	help.SetSize(width, logHeight)
 	for i := range services {
 		services[i].Log.SetSize(width, logHeight)
+		services[i].SetTtySize(width, logHeight)
 	}
 }
 
@@ -296,7 +444,8 @@ func stopAllServices(quit bool) bool {
 	var anyRunning bool
 	for i := range services {
 		services[i].StateMutex.Lock()
-		if services[i].State != service.StateStopped {
+		services[i].CancelRestart()
+		if services[i].State != service.StateStopped && services[i].State != service.StateDone {
 			anyRunning = true
 			services[i].EndService()
 		}
@@ -386,6 +535,10 @@ var runningStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#00ff00"))
 var stoppingStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#ffff00"))
+var unhealthyStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#ff8800"))
+var doneStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#00aaff"))
 
 func (m model) headerView(width int) string {
 
@@ -400,14 +553,23 @@ func (m model) headerView(width int) string {
 		visibleServiceIndexes := visibleServiceIndexes()
 		for _, i := range visibleServiceIndexes {
 			var stateStyle *lipgloss.Style
+			stateSymbol := "●"
 			services[i].StateMutex.RLock()
+			restartCount := services[i].RestartCount
 			switch services[i].State {
 			case service.StateRunning:
-				stateStyle = &runningStyle
+				if services[i].Unhealthy {
+					stateStyle = &unhealthyStyle
+				} else {
+					stateStyle = &runningStyle
+				}
 			case service.StateStopping:
 				stateStyle = &stoppingStyle
 			case service.StateStarting:
 				stateStyle = &stoppingStyle
+			case service.StateDone:
+				stateStyle = &doneStyle
+				stateSymbol = "✓"
 			default:
 				stateStyle = &stoppedStyle
 			}
@@ -419,7 +581,11 @@ func (m model) headerView(width int) string {
 			} else {
 				tabStyle = &altCmdStyle
 			}
-			addTab(&header, tabStyle.Render(" ")+stateStyle.Inherit(*tabStyle).Render("●")+tabStyle.Render(" "+services[i].Name+" "), width, &remainingWidth)
+			name := services[i].Name
+			if restartCount > 0 {
+				name += fmt.Sprintf(" ↻%d", restartCount)
+			}
+			addTab(&header, tabStyle.Render(" ")+stateStyle.Inherit(*tabStyle).Render(stateSymbol)+tabStyle.Render(" "+name+" "), width, &remainingWidth)
 		}
 		hiddenCount := len(services) - len(visibleServiceIndexes)
 		if hiddenCount > 0 {
@@ -487,6 +653,7 @@ var statusBarBackgroundColors = []color.Color{
 	lipgloss.Color("#120ce3"),
 	lipgloss.Color("#0000c3"),
 	lipgloss.Color("#0000a3"),
+	lipgloss.Color("#000083"),
 }
 
 func (m model) footerView(width int) string {
@@ -511,21 +678,25 @@ func (m model) footerView(width int) string {
 			statusBarItems = append(statusBarItems, fmt.Sprintf("Log: %s", formatDataSize(activeService.Log.GetContentSize())))
 		}
 
-		if activeService.Pid != 0 {
-			statusBarItems = append(statusBarItems, fmt.Sprintf("PID %d", activeService.Pid))
+		if len(activeService.Pids) == 1 {
+			statusBarItems = append(statusBarItems, fmt.Sprintf("PID %d", activeService.Pids[0]))
+		} else if len(activeService.Pids) > 1 {
+			pids := make([]string, len(activeService.Pids))
+			for i, pid := range activeService.Pids {
+				pids[i] = strconv.Itoa(pid)
+			}
+			statusBarItems = append(statusBarItems, "PIDs "+strings.Join(pids, ", "))
 		}
 
-		var status string
+		if activeService.Usage != nil {
+			statusBarItems = append(statusBarItems, formatUsage(activeService.Usage))
+		}
 
-		if activeService.State == service.StateStopping {
-			status = "Stopping"
-		} else if activeService.State == service.StateStarting {
-			if len(activeService.WaitList) != 0 {
-				status = "Waiting for: " + strings.Join(activeService.WaitList, ", ")
-			} else {
-				status = "Starting"
-			}
+		if activeService.RestartCount > 0 {
+			statusBarItems = append(statusBarItems, fmt.Sprintf("Restarts: %d", activeService.RestartCount))
 		}
+
+		status := activeService.StatusText()
 		if status != "" {
 			statusBarItems = append(statusBarItems, status)
 		}
@@ -587,13 +758,11 @@ var popup string
 var activeMutex sync.RWMutex
 
 type contextDefinition struct {
-	Name     string `yaml:"name"`
-	Services map[string]struct {
-		Name        string
-		Path        string
-		Commands    []service.Command
-		Healthcheck service.Healthcheck
-	} `yaml:"services"`
+	Name     string                        `yaml:"name"`
+	Services map[string]service.Definition `yaml:"services"`
+	Env      map[string]string             `yaml:"env"`
+	EnvFile  []string                      `yaml:"envFile"`
+	Groups   map[string][]string           `yaml:"groups"`
 }
 
 type Context struct {
@@ -604,6 +773,51 @@ type Context struct {
 
 var p *tea.Program
 
+var startupGroup string
+var startupServices []string
+var httpAddress string
+
+func startDashboard(program service.Sender) *http.Server {
+	if httpAddress == "" {
+		return nil
+	}
+	server, err := dashboard.Serve(httpAddress, context.Name, program, slices.Clone(services))
+	if err != nil {
+		fmt.Println("Error starting dashboard: ", err)
+		os.Exit(1)
+	}
+	return server
+}
+
+func resolveServicePath(contextDir string, servicePath string) string {
+	if servicePath == "" {
+		return contextDir
+	} else if !filepath.IsAbs(servicePath) {
+		servicePath, _ = filepath.Abs(filepath.Join(contextDir, servicePath))
+	}
+	return servicePath
+}
+
+// flagValue returns the value of a flag given as "--name value" or "--name=value",
+// a flag without a value is an error instead of being ignored or taking the next flag as its value
+func flagValue(flags []string, name string) (string, bool, error) {
+	for i, flag := range flags {
+		var value string
+		if v, ok := strings.CutPrefix(flag, name+"="); ok {
+			value = v
+		} else if flag == name && i+1 < len(flags) {
+			value = flags[i+1]
+		} else if flag != name {
+			continue
+		}
+		if value == "" || strings.HasPrefix(value, "--") {
+			return "", false, fmt.Errorf("missing value for %s", name)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
 func main() {
 
 	if len(os.Args) < 2 {
@@ -611,6 +825,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
+	if os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+
 	fileName := os.Args[1]
 
 	ymlData, err := os.ReadFile(fileName)
@@ -619,17 +841,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	if problems := validateContext(fileName, ymlData); len(problems) != 0 {
+		fmt.Println("Invalid context file:")
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		os.Exit(1)
+	}
+
 	var contextDefinition contextDefinition
 
 	if err := yaml.ImportYaml(ymlData, &contextDefinition); err != nil {
 		fmt.Println("Error reading yaml: ", err)
 		os.Exit(1)
 	}
-	if len(contextDefinition.Services) == 0 {
-		fmt.Println("No services defined, must define at least 1 service.")
-		os.Exit(1)
-	}
-	// TODO: yaml validataion
 
 	context = &Context{}
 	if contextDefinition.Name == "" {
@@ -663,6 +888,30 @@ func main() {
 		context.Settings.ServiceOrder = make([]string, 0)
 	}
 
+	groupKeys, _ := yaml.GetKeys(ymlData, "$.groups")
+	for _, groupKey := range groupKeys {
+		groups = append(groups, group{Name: groupKey, Services: contextDefinition.Groups[groupKey]})
+	}
+
+	if len(os.Args) > 2 {
+		groupName, ok, err := flagValue(os.Args[2:], "--group")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if ok {
+			if findGroup(groupName) == nil {
+				fmt.Printf("Unknown group: %s\n", groupName)
+				os.Exit(1)
+			}
+			startupGroup = groupName
+		}
+		if httpAddress, _, err = flagValue(os.Args[2:], "--http"); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	existingServiceKeys, _ := yaml.GetKeys(ymlData, "$.services")
 
 	serviceIndex := 0
@@ -685,6 +934,7 @@ func main() {
 	}
 
 	contextDir := filepath.Dir(absoluteFilePath)
+	contextEnv := env.Source{Dir: contextDir, Env: contextDefinition.Env, EnvFile: contextDefinition.EnvFile}
 	services = make([]*service.Service, len(finalServiceKeys))
 	service.Services = make(map[string]*service.Service)
 	for i, serviceKey := range finalServiceKeys {
@@ -696,16 +946,37 @@ func main() {
 			name = serviceKey
 		}
 
-		servicePath := s.Path
-		if servicePath == "" {
-			servicePath = contextDir
-		} else if !filepath.IsAbs(servicePath) {
-			servicePath, _ = filepath.Abs(filepath.Join(contextDir, servicePath))
-		}
+		newService := service.New(serviceKey, name, resolveServicePath(contextDir, s.Path), s, &configuration, contextEnv)
+		services[i] = newService
+		service.Services[serviceKey] = newService
+	}
 
-		newService := service.New(serviceKey, name, servicePath, s.Commands, &configuration, s.Healthcheck)
-		services[i] = &newService
-		service.Services[serviceKey] = &newService
+	for _, serviceKey := range existingServiceKeys {
+		if contextDefinition.Services[serviceKey].Autostart {
+			startupServices = append(startupServices, serviceKey)
+		}
+	}
+	if len(os.Args) > 2 {
+		startFlag, ok, err := flagValue(os.Args[2:], "--start")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if ok {
+			for serviceKey := range strings.SplitSeq(startFlag, ",") {
+				serviceKey = strings.TrimSpace(serviceKey)
+				if serviceKey == "" {
+					continue
+				}
+				if _, ok := service.Services[serviceKey]; !ok {
+					fmt.Printf("Unknown service: %s\n", serviceKey)
+					os.Exit(1)
+				}
+				if !slices.Contains(startupServices, serviceKey) {
+					startupServices = append(startupServices, serviceKey)
+				}
+			}
+		}
 	}
 
 	if context.Settings.ActiveService != "" {
@@ -713,6 +984,10 @@ func main() {
 			activeIndex = savedActiveServiceIndex
 		}
 	}
+	if len(os.Args) > 2 && slices.Contains(os.Args[2:], "--headless") {
+		os.Exit(runHeadless())
+	}
+
 	activeService = services[activeIndex]
 	help = log.New(&configuration)
 
@@ -734,13 +1009,24 @@ func main() {
 		"[shift+left] or [shift+h] or [shift+right] or [shift+l] to swap places between services",
 		"",
 		"[s] to stop all running services",
+		"[r] to restart the active service",
+		"[shift+r] to restart the active service and every service that requires it",
 		"[a] to toggle between showing only running services",
+		"[e] to show the environment variables of the active service",
+		"[m] to show the memory and CPU usage of the running services",
+		"[p] to show the process tree of the active service and send signals to its processes",
+		"[g] to pick a group of services to start or stop",
 		"",
 		"[f] to enter filter mode",
 		"[/] to enter search mode",
 		"[q] or [esc] to exit filter/search mode",
 		"[tab] or [shift+tab] to change filter/search type (case insensitive, case sensitive or regex)",
 		"[n] or [shift+n] to move between search results",
+		"",
+		"[i] to enter input mode, [enter] sends the typed line to the active service",
+		"[shift+i] to enter raw input mode, every key is sent to the active service",
+		"[esc] to exit input mode",
+		"input mode is only available for services with stdin: true or tty: true",
 	}
 
 	for _, line := range helpContent {
@@ -756,8 +1042,17 @@ func main() {
 	p = tea.NewProgram(
 		model{},
 	)
+	controlServer, err := control.Listen(control.SocketPath(context.FilePath), p, slices.Clone(services))
+	if err != nil {
+		popup = "Control socket not available:\n" + err.Error()
+	}
+	dashboardServer := startDashboard(p)
+	go sampleUsage(slices.Clone(services))
 	for i := range services {
 		services[i].Program = p
+		if services[i].Watch != nil {
+			go services[i].WatchFiles()
+		}
 	}
 
 	if len(os.Args) > 2 {
@@ -777,7 +1072,14 @@ func main() {
 			p.Send(service.ContentUpdateMsg{})
 		}
 	}()
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	if controlServer != nil {
+		controlServer.Close()
+	}
+	if dashboardServer != nil {
+		dashboardServer.Close()
+	}
+	if err != nil {
 		fmt.Println("Error running bubbletea program: ", err)
 		os.Exit(1)
 	}
@@ -789,8 +1091,6 @@ func main() {
 // TODO: remember timestamp rules per context service
 // TODO: style sysout messages
 // TODO: style syserr messages
-// TODO: allow overriding success codes for commands
-// TODO: automatically send second stop after 30s and then every 5s after that
 // TODO: make windows gradle/maven/java kill optional
 // TODO: add kill options as regex to config
 // TODO: add command replacement regex to config
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andresrobam/leggo/service"
+	"github.com/andresrobam/leggo/sys"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const maxCommandLength = 100
+
+const processPickerRefreshInterval = time.Second
+
+var processSignals = []struct {
+	key    string
+	signal string
+}{
+	{"t", "SIGTERM"},
+	{"i", "SIGINT"},
+	{"h", "SIGHUP"},
+	{"x", "SIGKILL"},
+}
+
+type processEntry struct {
+	process sys.Process
+	depth   int
+	command string
+}
+
+var processPicker bool
+var processPickerService *service.Service
+var processPickerIndex int
+var processPickerEntries []processEntry
+var processPickerMessage string
+var processPickerRefreshedAt time.Time
+
+// processTree lists the processes started from the process group leaders depth first,
+// followed by the rest of the group members whose parents have already exited
+func processTree(pids []int) ([]processEntry, error) {
+	processes, err := sys.Processes()
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]sys.Process)
+	for _, process := range processes {
+		children[process.Ppid] = append(children[process.Ppid], process)
+	}
+	var entries []processEntry
+	visited := make(map[int]bool)
+	var visit func(process sys.Process, depth int)
+	visit = func(process sys.Process, depth int) {
+		if visited[process.Pid] {
+			return
+		}
+		visited[process.Pid] = true
+		entries = append(entries, processEntry{process: process, depth: depth, command: sys.CommandLine(process.Pid)})
+		for _, child := range children[process.Pid] {
+			visit(child, depth+1)
+		}
+	}
+	for _, process := range processes {
+		if slices.Contains(pids, process.Pid) {
+			visit(process, 0)
+		}
+	}
+	for _, process := range processes {
+		if slices.Contains(pids, process.Pgid) {
+			visit(process, 0)
+		}
+	}
+	return entries, nil
+}
+
+func openProcessPicker(s *service.Service) {
+	processPicker = true
+	processPickerService = s
+	processPickerIndex = 0
+	processPickerMessage = ""
+	refreshProcessPicker()
+}
+
+func refreshProcessPicker() {
+	s := processPickerService
+	s.StateMutex.RLock()
+	pids := slices.Clone(s.Pids)
+	s.StateMutex.RUnlock()
+	var selectedPid int
+	if len(processPickerEntries) != 0 {
+		selectedPid = processPickerEntries[processPickerIndex].process.Pid
+	}
+	entries, err := processTree(pids)
+	if errors.Is(err, errors.ErrUnsupported) {
+		processPickerMessage = "Listing processes is not supported on this platform"
+	} else if err != nil {
+		processPickerMessage = "Error listing processes: " + err.Error()
+	}
+	processPickerEntries = entries
+	processPickerRefreshedAt = time.Now()
+	if i := slices.IndexFunc(entries, func(entry processEntry) bool {
+		return entry.process.Pid == selectedPid
+	}); i != -1 {
+		processPickerIndex = i
+	}
+	processPickerIndex = max(min(processPickerIndex, len(entries)-1), 0)
+	popup = processPickerView()
+}
+
+func closeProcessPicker() {
+	processPicker = false
+	processPickerService = nil
+	processPickerEntries = nil
+	popup = ""
+}
+
+func handleProcessPickerKey(k string) {
+	switch k {
+	case "up", "k":
+		if len(processPickerEntries) != 0 {
+			processPickerIndex = (processPickerIndex + len(processPickerEntries) - 1) % len(processPickerEntries)
+		}
+	case "down", "j":
+		if len(processPickerEntries) != 0 {
+			processPickerIndex = (processPickerIndex + 1) % len(processPickerEntries)
+		}
+	case "r":
+		processPickerMessage = ""
+		refreshProcessPicker()
+		return
+	case "esc", "q", "p":
+		closeProcessPicker()
+		return
+	default:
+		i := slices.IndexFunc(processSignals, func(s struct{ key, signal string }) bool {
+			return s.key == k
+		})
+		if i == -1 || len(processPickerEntries) == 0 {
+			break
+		}
+		signal := processSignals[i].signal
+		process := processPickerEntries[processPickerIndex].process
+		pid := process.Pid
+		// the list can be a second old, make sure the pid hasn't been reused by another process since
+		if current, err := sys.FindProcess(pid); err != nil || !current.StartTime.Equal(process.StartTime) {
+			processPickerMessage = fmt.Sprintf("Process %d has exited", pid)
+			refreshProcessPicker()
+			return
+		}
+		if err := sys.SignalProcess(pid, signal); err != nil {
+			processPickerMessage = fmt.Sprintf("Error sending %s to %d: %s", signal, pid, err)
+		} else {
+			processPickerMessage = fmt.Sprintf("Sent %s to %d", signal, pid)
+		}
+	}
+	popup = processPickerView()
+}
+
+func formatAge(startTime time.Time) string {
+	age := time.Since(startTime)
+	if age < time.Minute {
+		return age.Round(time.Second).String()
+	}
+	return strings.TrimSuffix(age.Round(time.Minute).String(), "0s")
+}
+
+func processPickerView() string {
+	lines := []string{"Processes of " + processPickerService.Name + ":", ""}
+	if len(processPickerEntries) == 0 && processPickerMessage == "" {
+		lines = append(lines, "No running processes")
+	}
+	pidWidth := len("PID")
+	for _, entry := range processPickerEntries {
+		pidWidth = max(pidWidth, len(fmt.Sprint(entry.process.Pid)))
+	}
+	if len(processPickerEntries) != 0 {
+		lines = append(lines, fmt.Sprintf("  %-*s  %7s  %s", pidWidth, "PID", "AGE", "COMMAND"))
+	}
+	for i, entry := range processPickerEntries {
+		cursor := "  "
+		if i == processPickerIndex {
+			cursor = "> "
+		}
+		command := ansi.Truncate(entry.command, maxCommandLength, "…")
+		indent := strings.Repeat("  ", entry.depth)
+		lines = append(lines, fmt.Sprintf("%s%-*d  %7s  %s%s", cursor, pidWidth, entry.process.Pid, formatAge(entry.process.StartTime), indent, command))
+	}
+	if processPickerMessage != "" {
+		lines = append(lines, "", processPickerMessage)
+	}
+	signalHelp := make([]string, len(processSignals))
+	for i, s := range processSignals {
+		signalHelp[i] = fmt.Sprintf("[%s] %s", s.key, s.signal)
+	}
+	lines = append(lines, "", strings.Join(signalHelp, ", "), "[r] to refresh, [esc] to close")
+	return strings.Join(lines, "\n")
+}
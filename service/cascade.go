@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Cascade struct {
+	Stop         bool     `yaml:"stop"`
+	Restart      bool     `yaml:"restart"`
+	Dependencies []string `yaml:"dependencies"`
+}
+
+func (s *Service) RequiredServices() []string {
+	var requiredServices []string
+	for _, c := range s.Commands {
+		for _, requiredService := range c.Requires {
+			if !slices.Contains(requiredServices, requiredService) {
+				requiredServices = append(requiredServices, requiredService)
+			}
+		}
+	}
+	return requiredServices
+}
+
+func (s *Service) cascadesFrom(dependency string) bool {
+	if !s.Cascade.Stop || !slices.Contains(s.RequiredServices(), dependency) {
+		return false
+	}
+	return len(s.Cascade.Dependencies) == 0 || slices.Contains(s.Cascade.Dependencies, dependency)
+}
+
+func (s *Service) runningDependents(all bool) []*Service {
+	var dependents []*Service
+	for _, dependent := range Services {
+		if dependent == s || !slices.Contains(dependent.RequiredServices(), s.Key) {
+			continue
+		}
+		if !all && !dependent.cascadesFrom(s.Key) {
+			continue
+		}
+		if state := dependent.GetState(); state != StateStopped && state != StateDone {
+			dependents = append(dependents, dependent)
+		}
+	}
+	slices.SortFunc(dependents, func(a *Service, b *Service) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return dependents
+}
+
+// Stop stops the service after first stopping every running service that cascades from it
+func (s *Service) Stop() {
+	s.stopWithDependents(false)
+}
+
+// RestartWithDependents restarts the service and every running service that transitively requires it,
+// dependents are stopped first and started again in dependency order once the service is back up
+func (s *Service) RestartWithDependents() {
+	switch s.State {
+	case StateStopped, StateDone:
+		s.StartService()
+	case StateStopping:
+		s.RestartPending = true
+	default:
+		s.RestartPending = true
+		if len(s.StopWaitList) == 0 {
+			s.stopWithDependents(true)
+		}
+	}
+}
+
+func (s *Service) stopWithDependents(all bool) {
+	if len(s.StopWaitList) != 0 {
+		s.StopWaitList = nil
+		s.EndService()
+		return
+	}
+	dependents := s.runningDependents(all)
+	if len(dependents) == 0 {
+		s.EndService()
+		return
+	}
+	for _, dependent := range dependents {
+		s.StopWaitList = append(s.StopWaitList, dependent.Key)
+	}
+	s.addSysoutLine(fmt.Sprintf("Stopping dependent services first: %s", strings.Join(s.StopWaitList, ", ")))
+	for _, dependent := range dependents {
+		dependent.StateMutex.Lock()
+		dependent.stopForDependency(s.Key, all)
+		dependent.StateMutex.Unlock()
+	}
+}
+
+func (s *Service) stopForDependency(dependency string, restart bool) {
+	if (restart || s.Cascade.Restart) && !slices.Contains(s.ResumeAfter, dependency) {
+		s.ResumeAfter = append(s.ResumeAfter, dependency)
+	}
+	if s.State == StateStopped || s.State == StateStopping || s.State == StateDone {
+		return
+	}
+	s.addSysoutLine(fmt.Sprintf("Required service %s is going down, stopping", dependency))
+	s.stopWithDependents(restart)
+}
+
+func (s *Service) DependencyStopped(dependency string) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	if s.State == StateStopped || s.State == StateStopping || s.State == StateDone || !s.cascadesFrom(dependency) {
+		return
+	}
+	s.stopForDependency(dependency, false)
+}
+
+// DependencyFailed stops the service when it is waiting for a required service that failed and won't be restarted
+func (s *Service) DependencyFailed(dependency string) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	if !slices.Contains(s.WaitList, dependency) {
+		return
+	}
+	s.addSyserrLine(fmt.Sprintf("Required service %s failed, stopping", dependency))
+	s.Failed = true
+	s.EndService()
+}
+
+func (s *Service) DependentStopped(dependent string) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	i := slices.Index(s.StopWaitList, dependent)
+	if i == -1 {
+		return
+	}
+	s.StopWaitList = slices.Delete(s.StopWaitList, i, i+1)
+	if len(s.StopWaitList) == 0 {
+		s.addSysoutLine("Dependent services stopped")
+		s.EndService()
+	}
+}
+
+func (s *Service) DependencyStarted(dependency string) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	i := slices.Index(s.ResumeAfter, dependency)
+	if i == -1 {
+		return
+	}
+	s.ResumeAfter = slices.Delete(s.ResumeAfter, i, i+1)
+	if len(s.ResumeAfter) == 0 && s.State == StateStopped {
+		s.addSysoutLine(fmt.Sprintf("Required service %s is up again, restarting", dependency))
+		s.restarting = true
+		s.StartService()
+		s.restarting = false
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/andresrobam/leggo/env"
+	"github.com/andresrobam/leggo/sys"
+)
+
+func (s *Service) commandDir(c Command) (string, error) {
+	if c.Path == "" {
+		return s.Path, nil
+	}
+	if filepath.IsAbs(c.Path) {
+		return c.Path, nil
+	}
+	return filepath.Abs(filepath.Join(s.Path, c.Path))
+}
+
+func (s *Service) Environment(c *Command) (map[string]string, error) {
+	sources := []env.Source{
+		s.ContextEnv,
+		{Dir: s.Path, Env: s.Env, EnvFile: s.EnvFile},
+	}
+	if c != nil {
+		dir, err := s.commandDir(*c)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, env.Source{Dir: dir, Env: c.Env, EnvFile: c.EnvFile})
+	}
+	return env.Resolve(sources...)
+}
+
+func (s *Service) sideCommand(ctx context.Context, command string, dir string, vars map[string]string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, s.Configuration.CommandExecutor, s.Configuration.CommandArgument, command)
+	cmd.SysProcAttr = sys.GetSysProcAttr()
+	cmd.Dir = dir
+	cmd.Env = env.Environ(vars)
+	cmd.Cancel = func() error {
+		return sys.Kill(cmd.Process)
+	}
+	return cmd
+}
+
+func (s *Service) runSideCommand(cmd *exec.Cmd) error {
+	outPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("opening stdout pipe %w", err)
+	}
+	errPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("opening stderr pipe %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+	go writeFromPipe(&outPipe, false, "", s, wg)
+	go writeFromPipe(&errPipe, true, "", s, wg)
+	wg.Wait()
+	return cmd.Wait()
+}
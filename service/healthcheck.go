@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andresrobam/leggo/lock"
+)
+
+type Healthcheck struct {
+	Command          string
+	Period           int
+	LockUntilHealthy []string
+	Http             *HttpCheck `yaml:"http"`
+	Tcp              string     `yaml:"tcp"`
+	File             string     `yaml:"file"`
+	Timeout          int        `yaml:"timeout"`
+	Retries          int        `yaml:"retries"`
+	InitialDelay     int        `yaml:"initialDelay"`
+	StartupDeadline  int        `yaml:"startupDeadline"`
+	Liveness         *Liveness  `yaml:"liveness"`
+}
+
+type Liveness struct {
+	Period           int  `yaml:"period"`
+	FailureThreshold int  `yaml:"failureThreshold"`
+	Restart          bool `yaml:"restart"`
+}
+
+type HttpCheck struct {
+	Url    string `yaml:"url"`
+	Status int    `yaml:"status"`
+	Body   string `yaml:"body"`
+}
+
+const maxHealthcheckBodyBytes = 1024 * 1024
+const defaultHealthcheckTimeout = 10
+const defaultLivenessPeriod = 5
+const defaultLivenessFailureThreshold = 3
+
+func (h Healthcheck) Defined() bool {
+	return h.Command != "" || h.Http != nil || h.Tcp != "" || h.File != ""
+}
+
+func (h Healthcheck) description() string {
+	var checks []string
+	if h.Command != "" {
+		checks = append(checks, fmt.Sprintf("command \"%s\"", h.Command))
+	}
+	if h.Http != nil {
+		checks = append(checks, fmt.Sprintf("http %s", h.Http.Url))
+	}
+	if h.Tcp != "" {
+		checks = append(checks, fmt.Sprintf("tcp %s", h.Tcp))
+	}
+	if h.File != "" {
+		checks = append(checks, fmt.Sprintf("file %s", h.File))
+	}
+	return strings.Join(checks, ", ")
+}
+
+func (s *Service) CheckHealth() {
+
+	if s.Healthcheck.InitialDelay > 0 {
+		<-time.After(time.Duration(s.Healthcheck.InitialDelay) * time.Second)
+	}
+	startupDeadline := time.Now().Add(time.Duration(s.Healthcheck.StartupDeadline) * time.Second)
+	var failures int
+
+	for {
+		if s.GetState() != StateStarting {
+			return
+		}
+
+		s.addSysoutLine(fmt.Sprintf("Running healthcheck: %s", s.Healthcheck.description()))
+		if err := s.runHealthcheck(); err != nil {
+			if s.GetState() != StateStarting {
+				return
+			}
+			failures++
+			s.addSyserrLine(fmt.Sprintf("Healthcheck failed: %s", err))
+			if s.Healthcheck.Retries > 0 && failures >= s.Healthcheck.Retries {
+				s.healthcheckFailed(fmt.Sprintf("healthcheck failed %d times", failures))
+				return
+			}
+		} else {
+			s.healthcheckPassed()
+			return
+		}
+
+		healthCheckPeriod := s.Healthcheck.Period
+		if healthCheckPeriod == 0 {
+			healthCheckPeriod = 1
+		}
+		<-time.After(time.Duration(healthCheckPeriod) * time.Second)
+
+		if s.Healthcheck.StartupDeadline > 0 && time.Now().After(startupDeadline) {
+			s.healthcheckFailed(fmt.Sprintf("not healthy after %ds", s.Healthcheck.StartupDeadline))
+			return
+		}
+	}
+}
+
+func (s *Service) healthcheckFailed(reason string) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	if s.State != StateStarting {
+		return
+	}
+	s.addSyserrLine(fmt.Sprintf("Service failed to become healthy: %s, stopping", reason))
+	s.Failed = true
+	s.healthcheckFailure = true
+	if len(s.Healthcheck.LockUntilHealthy) != 0 {
+		lock.LockMutex.Lock()
+		s.releaseLocks(s.Healthcheck.LockUntilHealthy)
+		lock.LockMutex.Unlock()
+	}
+	s.EndService()
+}
+
+func (s *Service) healthcheckPassed() {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	if s.State != StateStarting {
+		return
+	}
+	s.addSysoutLine("Healthcheck passed")
+	if len(s.Healthcheck.LockUntilHealthy) != 0 {
+		lock.LockMutex.Lock()
+		defer lock.LockMutex.Unlock()
+		s.releaseLocks(s.Healthcheck.LockUntilHealthy)
+	}
+	s.State = StateRunning
+	if s.Healthcheck.Liveness != nil {
+		go s.checkLiveness(s.step)
+	}
+	s.started()
+}
+
+func (s *Service) checkLiveness(st *step) {
+	period := s.Healthcheck.Liveness.Period
+	if period <= 0 {
+		period = defaultLivenessPeriod
+	}
+	failureThreshold := s.Healthcheck.Liveness.FailureThreshold
+	if failureThreshold <= 0 {
+		failureThreshold = defaultLivenessFailureThreshold
+	}
+	var failures int
+
+	for {
+		<-time.After(time.Duration(period) * time.Second)
+		if !s.livenessTarget(st) {
+			return
+		}
+		err := s.runHealthcheck()
+
+		s.StateMutex.Lock()
+		if s.step != st || s.State != StateRunning {
+			s.StateMutex.Unlock()
+			return
+		}
+		if err == nil {
+			failures = 0
+			if s.Unhealthy {
+				s.Unhealthy = false
+				s.addSysoutLine("Liveness check passed, service is healthy again")
+			}
+		} else {
+			failures++
+			s.addSyserrLine(fmt.Sprintf("Liveness check failed (%d/%d): %s", min(failures, failureThreshold), failureThreshold, err))
+			if failures >= failureThreshold && !s.Unhealthy {
+				s.Unhealthy = true
+				s.addSyserrLine("Service is unhealthy")
+				if s.Healthcheck.Liveness.Restart {
+					s.addSysoutLine("Restarting unhealthy service")
+					s.Restart()
+				}
+			}
+		}
+		s.StateMutex.Unlock()
+	}
+}
+
+func (s *Service) livenessTarget(st *step) bool {
+	s.StateMutex.RLock()
+	defer s.StateMutex.RUnlock()
+	return s.step == st && s.State == StateRunning
+}
+
+func (s *Service) runHealthcheck() error {
+	timeout := s.Healthcheck.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthcheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	err := s.runHealthchecks(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %ds", timeout)
+	}
+	return err
+}
+
+func (s *Service) runHealthchecks(ctx context.Context) error {
+	if s.Healthcheck.Command != "" {
+		if err := s.runCommandHealthcheck(ctx); err != nil {
+			return err
+		}
+	}
+	if s.Healthcheck.Http != nil {
+		if err := s.Healthcheck.Http.run(ctx); err != nil {
+			return err
+		}
+	}
+	if s.Healthcheck.Tcp != "" {
+		if err := runTcpHealthcheck(ctx, s.Healthcheck.Tcp); err != nil {
+			return err
+		}
+	}
+	if s.Healthcheck.File != "" {
+		if err := s.runFileHealthcheck(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) runCommandHealthcheck(ctx context.Context) error {
+	vars, err := s.Environment(nil)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+	hc := s.sideCommand(ctx, s.Healthcheck.Command, s.Path, vars)
+	if err := hc.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("command exited with code %d", exitErr.ExitCode())
+		}
+		return fmt.Errorf("running command: %w", err)
+	}
+	return nil
+}
+
+func (h *HttpCheck) run(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if h.Status != 0 {
+		if response.StatusCode != h.Status {
+			return fmt.Errorf("GET %s returned status %d, expected %d", h.Url, response.StatusCode, h.Status)
+		}
+	} else if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("GET %s returned status %d, expected 2xx", h.Url, response.StatusCode)
+	}
+	if h.Body == "" {
+		return nil
+	}
+	bodyRegex, err := regexp.Compile(h.Body)
+	if err != nil {
+		return fmt.Errorf("invalid body regex: %w", err)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxHealthcheckBodyBytes))
+	if err != nil {
+		return fmt.Errorf("reading body of GET %s: %w", h.Url, err)
+	}
+	if !bodyRegex.Match(body) {
+		return fmt.Errorf("body of GET %s did not match %s", h.Url, h.Body)
+	}
+	return nil
+}
+
+func runTcpHealthcheck(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (s *Service) runFileHealthcheck() error {
+	path := s.Healthcheck.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.Path, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("file %s does not exist", path)
+		}
+		return err
+	}
+	return nil
+}
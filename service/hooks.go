@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+type Hooks struct {
+	PreStart  []string `yaml:"preStart"`
+	PostStart []string `yaml:"postStart"`
+	PreStop   []string `yaml:"preStop"`
+	PostStop  []string `yaml:"postStop"`
+	OnCrash   []string `yaml:"onCrash"`
+	Timeout   int      `yaml:"timeout"`
+}
+
+const defaultHookTimeout = 60
+
+func (s *Service) runHook(ctx context.Context, name string, commands []string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	vars, err := s.Environment(nil)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+	timeout := s.Hooks.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	for _, command := range commands {
+		s.addSysoutLine(fmt.Sprintf("Running %s hook \"%s\"", name, command))
+		commandCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		err := s.runHookCommand(commandCtx, name, command, vars)
+		if err != nil && errors.Is(commandCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("\"%s\" timed out after %ds", command, timeout)
+		}
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) runHookCommand(ctx context.Context, name string, command string, vars map[string]string) error {
+	cmd := s.sideCommand(ctx, command, s.Path, vars)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	done := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			s.addSysoutLine(fmt.Sprintf("[%s] %s", name, scanner.Text()))
+		}
+		io.Copy(io.Discard, reader)
+		close(done)
+	}()
+	err := cmd.Run()
+	writer.Close()
+	<-done
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("\"%s\" exited with code %d", command, exitErr.ExitCode())
+	}
+	return err
+}
+
+// startHook runs the hook in the background and calls then with the state lock held,
+// then is not called if the hook gets cancelled by stopping the service
+func (s *Service) startHook(run func(ctx context.Context) error, then func(err error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.hookCancel = cancel
+	go func() {
+		err := run(ctx)
+		s.StateMutex.Lock()
+		defer s.StateMutex.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		s.hookCancel = nil
+		cancel()
+		then(err)
+	}()
+}
+
+func (s *Service) cancelHook() {
+	if s.hookCancel == nil {
+		return
+	}
+	s.hookCancel()
+	s.hookCancel = nil
+	s.addSysoutLine("Hook cancelled")
+}
+
+func (s *Service) runPreStart() {
+	s.State = StateStarting
+	s.startHook(func(ctx context.Context) error {
+		return s.runHook(ctx, "preStart", s.Hooks.PreStart)
+	}, func(err error) {
+		if s.State != StateStarting {
+			return
+		}
+		if err != nil {
+			s.handleCommandStartingError(fmt.Sprintf("preStart hook failed: %s, aborting start", err))
+			return
+		}
+		s.preStartDone = true
+		s.StartService()
+	})
+}
+
+func (s *Service) runPreStop() {
+	st := s.step
+	s.startHook(func(ctx context.Context) error {
+		return s.runHook(ctx, "preStop", s.Hooks.PreStop)
+	}, func(err error) {
+		if s.step != st || s.State != StateStopping || s.TermAttemptCount != 1 {
+			return
+		}
+		if err != nil {
+			s.addSyserrLine(fmt.Sprintf("preStop hook failed: %s", err))
+		}
+		s.stopProcess()
+	})
+}
+
+func (s *Service) runPostStop(crashed bool, finish func()) {
+	s.State = StateStopping
+	s.startHook(func(ctx context.Context) error {
+		if crashed {
+			if err := s.runHook(ctx, "onCrash", s.Hooks.OnCrash); err != nil && ctx.Err() == nil {
+				s.addSyserrLine(fmt.Sprintf("onCrash hook failed: %s", err))
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return s.runHook(ctx, "postStop", s.Hooks.PostStop)
+	}, func(err error) {
+		if err != nil {
+			s.addSyserrLine(fmt.Sprintf("postStop hook failed: %s", err))
+		}
+		finish()
+	})
+}
+
+func (s *Service) started() {
+	go s.Program.Send(ServiceStartedMsg{Service: s.Key})
+	if len(s.Hooks.PostStart) == 0 {
+		return
+	}
+	go func() {
+		if err := s.runHook(context.Background(), "postStart", s.Hooks.PostStart); err != nil {
+			s.addSyserrLine(fmt.Sprintf("postStart hook failed: %s", err))
+		}
+	}()
+}
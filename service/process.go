@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/andresrobam/leggo/sys"
+)
+
+type ParallelCommand struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+}
+
+type process struct {
+	cmd      *exec.Cmd
+	name     string
+	outPipe  *io.ReadCloser
+	errPipe  *io.ReadCloser
+	output   *sync.WaitGroup
+	exited   bool
+	exitCode int
+}
+
+func (p *process) prefix() string {
+	if p.name == "" {
+		return ""
+	}
+	return "[" + p.name + "] "
+}
+
+// step holds the processes of the running command, there's one process for every parallel command
+type step struct {
+	processes []*process
+	failed    *process
+}
+
+func (c Command) parallelCommands() []ParallelCommand {
+	if len(c.Parallel) == 0 {
+		return []ParallelCommand{{Command: c.Command}}
+	}
+	commands := make([]ParallelCommand, len(c.Parallel))
+	for i, parallelCommand := range c.Parallel {
+		commands[i] = parallelCommand
+		if commands[i].Name == "" {
+			commands[i].Name = fmt.Sprintf("%d", i+1)
+		}
+	}
+	return commands
+}
+
+func (st *step) parallel() bool {
+	return len(st.processes) > 1
+}
+
+func (st *step) pids() []int {
+	pids := make([]int, len(st.processes))
+	for i, p := range st.processes {
+		pids[i] = p.cmd.Process.Pid
+	}
+	return pids
+}
+
+func (st *step) signal(send func(process *os.Process) error) error {
+	var errs []error
+	for _, p := range st.processes {
+		if p.exited {
+			continue
+		}
+		if err := send(p.cmd.Process); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (st *step) exitCode(c Command) int {
+	if st.failed != nil {
+		return st.failed.exitCode
+	}
+	for _, p := range st.processes {
+		if !c.isSuccess(p.exitCode) {
+			return p.exitCode
+		}
+	}
+	return st.processes[0].exitCode
+}
+
+func (s *Service) sendStopSignal(st *step) error {
+	return st.signal(func(process *os.Process) error {
+		return sys.StopSignal(process, s.stopSignal())
+	})
+}
+
+func (s *Service) startProcess(p *process, single bool) error {
+	p.output = new(sync.WaitGroup)
+	if single && s.Tty {
+		ptyFile, err := sys.StartPty(p.cmd, s.Log.GetWidth(), s.Log.GetHeight())
+		if err != nil {
+			return fmt.Errorf("running command in tty: %w", err)
+		}
+		s.ptyFile = ptyFile
+		var ptyReader io.ReadCloser = ptyFile
+		var ptyWriter io.WriteCloser = ptyFile
+		p.outPipe = &ptyReader
+		s.inPipe = &ptyWriter
+		p.output.Add(1)
+		go writeFromPipe(p.outPipe, false, p.prefix(), s, p.output)
+		return nil
+	}
+
+	outPipe, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("opening stdout pipe %w", err)
+	}
+	p.outPipe = &outPipe
+
+	errPipe, err := p.cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("opening stderr pipe %w", err)
+	}
+	p.errPipe = &errPipe
+
+	if single && s.Stdin {
+		inPipe, err := p.cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("opening stdin pipe %w", err)
+		}
+		s.inPipe = &inPipe
+	}
+
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("running command: %w", err)
+	}
+	p.output.Add(2)
+	go writeFromPipe(p.outPipe, false, p.prefix(), s, p.output)
+	go writeFromPipe(p.errPipe, true, p.prefix(), s, p.output)
+	return nil
+}
+
+func (s *Service) waitProcess(st *step, p *process, wg *sync.WaitGroup) {
+	defer wg.Done()
+	p.output.Wait()
+	if err := (*p.outPipe).Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		s.addSyserrLine(fmt.Sprintf("%sError closing stdout pipe: %s", p.prefix(), err))
+	}
+	if p.errPipe != nil {
+		if err := (*p.errPipe).Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			s.addSyserrLine(fmt.Sprintf("%sError closing stderr pipe: %s", p.prefix(), err))
+		}
+	}
+	p.cmd.Wait()
+
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	p.exited = true
+	p.exitCode = p.cmd.ProcessState.ExitCode()
+	if !st.parallel() {
+		return
+	}
+	c := s.Commands[s.ActiveCommandIndex]
+	if c.isSuccess(p.exitCode) || c.isStop(p.exitCode) || s.State == StateStopping || st.failed != nil {
+		s.addSysoutLine(fmt.Sprintf("%sProcess exited with exit code: %d", p.prefix(), p.exitCode))
+		return
+	}
+	st.failed = p
+	s.addSyserrLine(fmt.Sprintf("%sProcess failed with exit code: %d, stopping parallel commands", p.prefix(), p.exitCode))
+	s.stopParallel(st)
+}
+
+func (s *Service) stopParallel(st *step) {
+	if err := s.sendStopSignal(st); err != nil {
+		s.addSyserrLine(fmt.Sprintf("Error closing process: %s", err))
+	}
+	kill := s.stopTimeout().Kill
+	if kill <= 0 {
+		return
+	}
+	s.stopTimers = append(s.stopTimers, time.AfterFunc(time.Duration(kill)*time.Second, func() {
+		s.StateMutex.Lock()
+		defer s.StateMutex.Unlock()
+		if s.step != st {
+			return
+		}
+		s.addSysoutLine(fmt.Sprintf("Parallel commands still running after %ds, killing processes", kill))
+		if err := st.signal(sys.Kill); err != nil {
+			s.addSyserrLine(fmt.Sprintf("Error killing process: %s", err))
+		}
+	}))
+}
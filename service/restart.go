@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const defaultInitialBackoff = 1
+const defaultMaxBackoff = 30
+
+type RestartPolicy struct {
+	Policy         string `yaml:"policy"`
+	MaxAttempts    int    `yaml:"maxAttempts"`
+	InitialBackoff int    `yaml:"initialBackoff"`
+	MaxBackoff     int    `yaml:"maxBackoff"`
+}
+
+func (r RestartPolicy) shouldRestart(failed bool) bool {
+	switch r.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+func (r RestartPolicy) maxBackoff() time.Duration {
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	return time.Duration(maxBackoff) * time.Second
+}
+
+func (r RestartPolicy) backoff(attempt int) time.Duration {
+	initialBackoff := r.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultInitialBackoff
+	}
+	backoff := time.Duration(initialBackoff) * time.Second
+	maxBackoff := r.maxBackoff()
+	for range attempt {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return min(backoff, maxBackoff)
+}
+
+func (s *Service) handleRestartPolicy(failed bool) {
+	if !s.RestartPolicy.shouldRestart(failed) {
+		return
+	}
+	if !s.startedAt.IsZero() && time.Since(s.startedAt) >= s.RestartPolicy.maxBackoff() {
+		s.RestartCount = 0
+	}
+	if s.RestartPolicy.MaxAttempts > 0 && s.RestartCount >= s.RestartPolicy.MaxAttempts {
+		s.addSyserrLine(fmt.Sprintf("Giving up after %d restart attempts", s.RestartCount))
+		return
+	}
+	backoff := s.RestartPolicy.backoff(s.RestartCount)
+	s.NextRestart = time.Now().Add(backoff)
+	s.addSysoutLine(fmt.Sprintf("Restarting in %s (attempt %s)", backoff, s.restartAttemptText(s.RestartCount+1)))
+	s.restartTimer = time.AfterFunc(backoff, func() {
+		s.Program.Send(RestartBackoffMsg{Service: s.Key})
+	})
+}
+
+func (s *Service) restartAttemptText(attempt int) string {
+	if s.RestartPolicy.MaxAttempts > 0 {
+		return fmt.Sprintf("%d/%d", attempt, s.RestartPolicy.MaxAttempts)
+	}
+	return fmt.Sprintf("%d", attempt)
+}
+
+func (s *Service) RestartAfterBackoff() {
+	if s.State != StateStopped || s.NextRestart.IsZero() {
+		return
+	}
+	s.NextRestart = time.Time{}
+	s.restartTimer = nil
+	s.RestartCount++
+	s.addSysoutLine(fmt.Sprintf("Restarting (attempt %s)", s.restartAttemptText(s.RestartCount)))
+	s.restarting = true
+	s.StartService()
+	s.restarting = false
+}
+
+// StartForWaiters starts the stopped service again for the dependents still waiting for it without resetting the restart count,
+// it returns false when the service failed and won't be restarted so the dependents have to stop waiting
+func (s *Service) StartForWaiters() bool {
+	if s.State != StateStopped || s.RestartPending || !s.NextRestart.IsZero() {
+		return true
+	}
+	if s.Failed {
+		return false
+	}
+	s.restarting = true
+	s.StartService()
+	s.restarting = false
+	return true
+}
+
+func (s *Service) Restart() {
+	switch s.State {
+	case StateStopped, StateDone:
+		s.StartService()
+	case StateStopping:
+		s.RestartPending = true
+	default:
+		s.RestartPending = true
+		if len(s.StopWaitList) == 0 {
+			s.Stop()
+		}
+	}
+}
+
+func (s *Service) CancelRestart() {
+	s.RestartPending = false
+	s.restartFrom = 0
+	s.healthcheckFailure = false
+	s.StopWaitList = nil
+	s.ResumeAfter = nil
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+	if !s.NextRestart.IsZero() {
+		s.NextRestart = time.Time{}
+		s.addSysoutLine("Pending restart cancelled")
+	}
+}
@@ -1,16 +1,17 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"maps"
 	"os/exec"
-	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/andresrobam/leggo/env"
 	"github.com/andresrobam/leggo/lock"
 	"github.com/andresrobam/leggo/sys"
 )
@@ -35,24 +36,36 @@ func forceDockerComposeAnsi(command string) string {
 }
 
 func (s *Service) transform(command string) string {
-	if s.Configuration.ForceDockerComposeAnsi {
+	if s.Configuration.ForceDockerComposeAnsi && !s.Tty {
 		return forceDockerComposeAnsi(command)
 	}
 	return command
 }
 
 type Command struct {
-	Command  string
-	Path     string
-	Locks    []string
-	Requires []string
-	Kill     bool
+	Command      string
+	Parallel     []ParallelCommand `yaml:"parallel"`
+	Path         string
+	Locks        []string
+	Requires     []string
+	Kill         bool
+	SuccessCodes []int             `yaml:"successCodes"`
+	StopCodes    []int             `yaml:"stopCodes"`
+	StopSignal   string            `yaml:"stopSignal"`
+	StopCommand  string            `yaml:"stopCommand"`
+	Env          map[string]string `yaml:"env"`
+	EnvFile      []string          `yaml:"envFile"`
 }
 
-type Healthcheck struct {
-	Command          string
-	Period           int
-	LockUntilHealthy []string
+func (c Command) isSuccess(exitCode int) bool {
+	if len(c.SuccessCodes) == 0 {
+		return exitCode == 0
+	}
+	return slices.Contains(c.SuccessCodes, exitCode)
+}
+
+func (c Command) isStop(exitCode int) bool {
+	return slices.Contains(c.StopCodes, exitCode)
 }
 
 func (s *Service) StartService() {
@@ -62,15 +75,21 @@ func (s *Service) StartService() {
 		s.Touched = true
 	}
 
-	if s.State == StateRunning || s.State == StateStopping || (s.State == StateStarting && s.cmd != nil) {
+	if s.State == StateRunning || s.State == StateStopping || (s.State == StateStarting && s.step != nil) {
 		return
 	}
 
-	if s.State == StateStopped && s.ActiveCommandIndex == 0 {
+	if (s.State == StateStopped || s.State == StateDone) && s.ActiveCommandIndex == 0 {
+		if !s.restarting {
+			s.CancelRestart()
+			s.RestartCount = 0
+		}
+		s.preStartDone = false
+		s.Failed = false
 		for i := range s.Commands {
 			s.State = StateStarting
 			for _, requiredService := range s.Commands[i].Requires {
-				if !slices.Contains(s.WaitList, requiredService) && Services[requiredService].State != StateRunning {
+				if !slices.Contains(s.WaitList, requiredService) && Services[requiredService].State != StateRunning && Services[requiredService].State != StateDone {
 					s.WaitList = append(s.WaitList, requiredService)
 					s.addSysoutLine(fmt.Sprintf("Starting required service: %s", requiredService))
 					defer func() {
@@ -90,6 +109,13 @@ func (s *Service) StartService() {
 		}
 	}
 
+	if s.ActiveCommandIndex == 0 && len(s.Hooks.PreStart) != 0 && !s.preStartDone {
+		if s.hookCancel == nil {
+			s.runPreStart()
+		}
+		return
+	}
+
 	lock.LockMutex.Lock()
 	defer lock.LockMutex.Unlock()
 
@@ -106,73 +132,76 @@ func (s *Service) StartService() {
 		}
 	}
 
-	command := s.transform(c.Command)
-
-	s.cmd = exec.Command(s.Configuration.CommandExecutor, s.Configuration.CommandArgument, command)
-	s.cmd.SysProcAttr = sys.GetSysProcAttr()
-
-	if c.Path != "" {
-		if filepath.IsAbs(c.Path) {
-			s.cmd.Dir = c.Path
-		} else {
-			var pathErr error
-			s.cmd.Dir, pathErr = filepath.Abs(filepath.Join(s.Path, c.Path))
-			if pathErr != nil {
-				s.handleCommandStartingError(fmt.Sprintf("Error: getting absolute path %s", pathErr))
-				return
-			}
-		}
-	} else {
-		s.cmd.Dir = s.Path
+	dir, pathErr := s.commandDir(c)
+	if pathErr != nil {
+		s.handleCommandStartingError(fmt.Sprintf("Error: getting absolute path %s", pathErr))
+		return
 	}
-	pathMessage := fmt.Sprintf(" in %s", s.Path)
-
-	outPipe, err := s.cmd.StdoutPipe()
-	if err != nil {
-		s.handleCommandStartingError(fmt.Sprintf("Error: opening stdout pipe %s", err))
+	vars, envErr := s.Environment(&c)
+	if envErr != nil {
+		s.handleCommandStartingError(fmt.Sprintf("Error: loading environment %s", envErr))
 		return
 	}
-	s.outPipe = &outPipe
+	pathMessage := fmt.Sprintf(" in %s", s.Path)
 
-	errPipe, err := s.cmd.StderrPipe()
-	if err != nil {
-		s.handleCommandStartingError(fmt.Sprintf("Error: opening stderr pipe %s", err))
-		return
+	var processes []*process
+	for _, parallelCommand := range c.parallelCommands() {
+		command := s.transform(parallelCommand.Command)
+		cmd := exec.Command(s.Configuration.CommandExecutor, s.Configuration.CommandArgument, command)
+		cmd.SysProcAttr = sys.GetSysProcAttr()
+		cmd.Dir = dir
+		cmd.Env = env.Environ(vars)
+		p := &process{cmd: cmd, name: parallelCommand.Name}
+		processes = append(processes, p)
+		s.addSysoutLine(fmt.Sprintf("%sRunning command \"%s\"%s", p.prefix(), command, pathMessage))
+	}
+	if len(vars) != 0 {
+		s.addSysoutLine(fmt.Sprintf("Environment: %s", strings.Join(slices.Sorted(maps.Keys(vars)), ", ")))
 	}
-	s.errPipe = &errPipe
 
-	s.addSysoutLine(fmt.Sprintf("Running command \"%s\"%s", command, pathMessage))
-	if err := s.cmd.Start(); err != nil {
-		s.handleCommandStartingError(fmt.Sprintf("Error running command: %s", err))
-		return
+	st := &step{}
+	for _, p := range processes {
+		if err := s.startProcess(p, len(processes) == 1); err != nil {
+			if err := st.signal(sys.Kill); err != nil {
+				s.addSyserrLine(fmt.Sprintf("Error killing process: %s", err))
+			}
+			for _, started := range st.processes {
+				go started.cmd.Wait()
+			}
+			s.inPipe = nil
+			s.ptyFile = nil
+			s.handleCommandStartingError(fmt.Sprintf("Error %s", err))
+			return
+		}
+		st.processes = append(st.processes, p)
 	}
-	s.Pid = s.cmd.Process.Pid
+	s.step = st
+	s.Pids = st.pids()
+	s.startedAt = time.Now()
 	s.State = StateStarting
 	lock.Lock(relevantLocks)
-	s.addSysoutLine(fmt.Sprintf("Process started with PID: %d", s.Pid))
-
-	wg := new(sync.WaitGroup)
-	wg.Add(2)
-	go writeFromPipe(&outPipe, false, s, wg)
-	go writeFromPipe(&errPipe, true, s, wg)
+	for _, p := range st.processes {
+		s.addSysoutLine(fmt.Sprintf("%sProcess started with PID: %d", p.prefix(), p.cmd.Process.Pid))
+	}
 
-	if s.ActiveCommandIndex == len(s.Commands)-1 {
-		if s.Healthcheck.Command != "" {
+	if s.ActiveCommandIndex == len(s.Commands)-1 && s.Type != TypeTask {
+		if s.Healthcheck.Defined() {
 			go s.CheckHealth()
 		} else {
 			s.State = StateRunning
 			if len(s.Healthcheck.LockUntilHealthy) != 0 {
 				s.releaseLocks(s.Healthcheck.LockUntilHealthy)
 			}
-			go s.Program.Send(ServiceStartedMsg{Service: s.Key})
+			s.started()
 		}
 	}
-	go handleRunningProcess(wg, &outPipe, s, &errPipe)
+	go handleRunningStep(s, st)
 }
 
 func (s *Service) handleCommandStartingError(errorMessage string) {
 	s.addSyserrLine(errorMessage)
 	s.State = StateStopped
+	s.Failed = true
 	if s.ActiveCommandIndex != 0 {
 		s.ActiveCommandIndex = 0
 		if len(s.Healthcheck.LockUntilHealthy) != 0 {
@@ -183,49 +212,6 @@ func (s *Service) handleCommandStartingError(errorMessage string) {
 	}
 }
 
-func (s *Service) CheckHealth() {
-
-	if s.State != StateStarting {
-		return
-	}
-
-	hc := exec.Command(s.Configuration.CommandExecutor, s.Configuration.CommandArgument, s.Healthcheck.Command)
-	hc.SysProcAttr = sys.GetSysProcAttr()
-	hc.Dir = s.Path
-	s.addSysoutLine(fmt.Sprintf("Running healthcheck \"%s\"", s.Healthcheck.Command))
-	if err := hc.Run(); err != nil {
-		if s.State != StateStarting {
-			return
-		}
-		s.addSyserrLine(fmt.Sprintf("Error running healthcheck: %s", err))
-	} else {
-		if hc.ProcessState.ExitCode() == 0 {
-			s.StateMutex.Lock()
-			defer s.StateMutex.Unlock()
-			if s.State != StateStarting {
-				return
-			}
-			s.addSysoutLine("Healthcheck passed")
-			if len(s.Healthcheck.LockUntilHealthy) != 0 {
-				lock.LockMutex.Lock()
-				defer lock.LockMutex.Unlock()
-				s.releaseLocks(s.Healthcheck.LockUntilHealthy)
-			}
-			s.State = StateRunning
-			go s.Program.Send(ServiceStartedMsg{Service: s.Key})
-			return
-		}
-		s.addSyserrLine(fmt.Sprintf("Healthcheck failed with exit code: %d", hc.ProcessState.ExitCode()))
-	}
-	healthCheckPeriod := s.Healthcheck.Period
-	if healthCheckPeriod == 0 {
-		healthCheckPeriod = 1
-	}
-	<-time.After(time.Duration(healthCheckPeriod) * time.Second)
-	s.CheckHealth()
-
-}
-
 func (s *Service) DoneWaiting(service string) {
 	s.StateMutex.Lock()
 	defer s.StateMutex.Unlock()
@@ -256,29 +242,37 @@ func (s *Service) HandleUnlock(locks []string) {
 	}
 }
 
-func handleRunningProcess(wg *sync.WaitGroup, outPipe *io.ReadCloser, s *Service, errPipe *io.ReadCloser) {
+func handleRunningStep(s *Service, st *step) {
 
+	wg := new(sync.WaitGroup)
+	for _, p := range st.processes {
+		wg.Add(1)
+		go s.waitProcess(st, p, wg)
+	}
 	wg.Wait()
 	s.StateMutex.Lock()
 	wasStopping := s.State == StateStopping
-	if err := (*outPipe).Close(); err != nil && err.Error() != "close |0: file already closed" {
-		s.addSyserrLine(fmt.Sprintf("Error closing stdout pipe: %s", err))
-	}
-	if err := (*errPipe).Close(); err != nil && err.Error() != "close |0: file already closed" {
-		s.addSyserrLine(fmt.Sprintf("Error closing stderr pipe: %s", err))
-	}
-	s.cmd.Wait()
-	s.Pid = 0
-
-	exitCode := s.cmd.ProcessState.ExitCode()
-	s.cmd = nil
-	message := fmt.Sprintf("Process finished with exit code: %d", exitCode)
-	s.addSysoutLine(message)
+	s.inPipe = nil
+	s.ptyFile = nil
+	s.Pids = nil
+	s.step = nil
 
 	activeCommandIndex := s.ActiveCommandIndex
+	c := s.Commands[activeCommandIndex]
+	exitCode := st.exitCode(c)
+	succeeded := c.isSuccess(exitCode)
+	stopped := wasStopping || c.isStop(exitCode)
+
+	if stopped && !succeeded {
+		s.addSysoutLine(fmt.Sprintf("Process stopped with exit code: %d", exitCode))
+	} else if succeeded {
+		s.addSysoutLine(fmt.Sprintf("Process finished with exit code: %d", exitCode))
+	} else {
+		s.addSyserrLine(fmt.Sprintf("Process failed with exit code: %d", exitCode))
+	}
 
 	var runNextCommand bool
-	if !wasStopping && exitCode == 0 {
+	if !stopped && succeeded {
 		s.ActiveCommandIndex++
 		if s.ActiveCommandIndex >= len(s.Commands) {
 			s.ActiveCommandIndex = 0
@@ -291,6 +285,7 @@ func handleRunningProcess(wg *sync.WaitGroup, outPipe *io.ReadCloser, s *Service
 	}
 
 	s.TermAttemptCount = 0
+	s.cancelStopEscalation()
 	lock.LockMutex.Lock()
 	s.releaseLocks(s.Commands[activeCommandIndex].Locks)
 	defer lock.LockMutex.Unlock()
@@ -302,7 +297,41 @@ func handleRunningProcess(wg *sync.WaitGroup, outPipe *io.ReadCloser, s *Service
 		if len(s.Healthcheck.LockUntilHealthy) != 0 {
 			s.releaseLocks(s.Healthcheck.LockUntilHealthy)
 		}
-		go s.Program.Send(ServiceStoppedMsg{Service: s.Key})
+		s.Unhealthy = false
+		finish := func() {
+			if s.Type == TypeTask && succeeded && !stopped {
+				s.State = StateDone
+				s.addSysoutLine("Task done")
+				s.started()
+				return
+			}
+			s.State = StateStopped
+			healthcheckFailure := s.healthcheckFailure
+			s.healthcheckFailure = false
+			if s.RestartPending {
+				s.RestartPending = false
+				s.ActiveCommandIndex = s.restartFrom
+				s.restartFrom = 0
+				go s.Program.Send(StartServiceMsg{Service: s.Key})
+			} else if healthcheckFailure {
+				// a service that never became healthy is handled like one that crashed
+				s.handleRestartPolicy(true)
+			} else if wasStopping {
+				s.RestartCount = 0
+			} else if !stopped {
+				s.handleRestartPolicy(!succeeded)
+			}
+			go s.Program.Send(ServiceStoppedMsg{Service: s.Key})
+		}
+		crashed := !stopped && !succeeded
+		if crashed {
+			s.Failed = true
+		}
+		if len(s.Hooks.PostStop) != 0 || (crashed && len(s.Hooks.OnCrash) != 0) {
+			s.runPostStop(crashed, finish)
+		} else {
+			finish()
+		}
 	}
 	s.StateMutex.Unlock()
 }
@@ -312,11 +341,13 @@ func (s *Service) EndService() {
 	s.State = StateStopping
 	s.WaitList = []string{}
 	s.addSysoutLine("Closing process")
-	if s.cmd != nil && s.cmd.Process != nil {
-		if err := s.end(); err != nil {
-			s.addSyserrLine(fmt.Sprintf("Error closing process: %s", err))
-		} else {
+	s.cancelHook()
+	if s.step != nil {
+		if s.TermAttemptCount == 1 && len(s.Hooks.PreStop) != 0 {
+			s.runPreStop()
 			go s.Program.Send(ServiceStoppingMsg{Service: s.Key})
+		} else {
+			s.stopProcess()
 		}
 	} else {
 		s.TermAttemptCount = 0
@@ -329,11 +360,28 @@ func (s *Service) EndService() {
 			s.releaseLocks(s.Healthcheck.LockUntilHealthy)
 		}
 		s.ActiveCommandIndex = 0
+		if s.RestartPending {
+			s.RestartPending = false
+			s.ActiveCommandIndex = s.restartFrom
+			s.restartFrom = 0
+			go s.Program.Send(StartServiceMsg{Service: s.Key})
+		}
 		go s.Program.Send(ServiceStoppedMsg{Service: s.Key})
 	}
 
 }
 
+func (s *Service) stopProcess() {
+	if err := s.end(); err != nil {
+		s.addSyserrLine(fmt.Sprintf("Error closing process: %s", err))
+		return
+	}
+	if s.TermAttemptCount == 1 {
+		s.scheduleStopEscalation()
+	}
+	go s.Program.Send(ServiceStoppingMsg{Service: s.Key})
+}
+
 func (s *Service) releaseLocks(locks []string) {
 	lock.Unlock(locks)
 	go func() {
@@ -355,10 +403,59 @@ func (c Command) shouldKill() bool {
 	return false
 }
 
+func (s *Service) stopSignal() string {
+	if c := s.Commands[s.ActiveCommandIndex]; c.StopSignal != "" {
+		return c.StopSignal
+	}
+	return s.StopSignal
+}
+
+func (s *Service) stopCommand() string {
+	if c := s.Commands[s.ActiveCommandIndex]; c.StopCommand != "" {
+		return c.StopCommand
+	}
+	return s.StopCommand
+}
+
 func (s *Service) end() error {
 
 	if s.Commands[s.ActiveCommandIndex].shouldKill() || s.TermAttemptCount > 2 {
-		return sys.Kill(s.cmd.Process)
+		return s.step.signal(sys.Kill)
+	}
+	if stopCommand := s.stopCommand(); stopCommand != "" && s.TermAttemptCount == 1 {
+		return s.runStopCommand(stopCommand)
+	}
+	return s.sendStopSignal(s.step)
+}
+
+func (s *Service) runStopCommand(stopCommand string) error {
+	c := s.Commands[s.ActiveCommandIndex]
+	dir, err := s.commandDir(c)
+	if err != nil {
+		return err
+	}
+	vars, err := s.Environment(&c)
+	if err != nil {
+		return err
 	}
-	return sys.GracefulStop(s.cmd.Process)
+	st := s.step
+	s.addSysoutLine(fmt.Sprintf("Running stop command \"%s\"", stopCommand))
+	go func() {
+		err := s.runSideCommand(s.sideCommand(context.Background(), stopCommand, dir, vars))
+		s.StateMutex.Lock()
+		defer s.StateMutex.Unlock()
+		if err == nil {
+			s.addSysoutLine("Stop command finished")
+			return
+		}
+		s.addSyserrLine(fmt.Sprintf("Error running stop command: %s", err))
+		if s.step != st || s.State != StateStopping {
+			return
+		}
+		s.addSysoutLine("Falling back to stop signal")
+		if err := s.sendStopSignal(st); err != nil {
+			s.addSyserrLine(fmt.Sprintf("Error closing process: %s", err))
+		}
+	}()
+	return nil
 }
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatusText describes what the service is currently doing, the state lock should be held when calling it
+func (s *Service) StatusText() string {
+	if len(s.StopWaitList) != 0 {
+		return "Waiting for dependents to stop: " + strings.Join(s.StopWaitList, ", ")
+	} else if s.State == StateRunning && s.Unhealthy {
+		return "Unhealthy"
+	} else if s.State == StateStopped && len(s.ResumeAfter) != 0 {
+		return "Resumes after: " + strings.Join(s.ResumeAfter, ", ")
+	} else if !s.NextRestart.IsZero() {
+		return fmt.Sprintf("Restarting in %s", time.Until(s.NextRestart).Round(time.Second))
+	} else if s.State == StateStopping {
+		status := "Stopping"
+		if countdown := s.StopCountdown(); countdown != "" {
+			status += " (" + countdown + ")"
+		}
+		return status
+	} else if s.State == StateDone {
+		return "Done"
+	} else if s.State == StateStopped && s.Failed {
+		return "Failed"
+	} else if s.State == StateStarting {
+		if len(s.WaitList) != 0 {
+			return "Waiting for: " + strings.Join(s.WaitList, ", ")
+		}
+		return "Starting"
+	}
+	return ""
+}
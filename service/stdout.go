@@ -2,14 +2,21 @@ package service
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os/exec"
+	"os"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"github.com/andresrobam/leggo/config"
+	"github.com/andresrobam/leggo/env"
 	"github.com/andresrobam/leggo/log"
+	"github.com/andresrobam/leggo/sys"
 )
 
 type State int
@@ -19,6 +26,27 @@ const (
 	StateStarting
 	StateRunning
 	StateStopping
+	StateDone
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateDone:
+		return "done"
+	default:
+		return "stopped"
+	}
+}
+
+const (
+	TypeService = "service"
+	TypeTask    = "task"
 )
 
 type LineType int
@@ -33,22 +61,56 @@ const (
 type Service struct {
 	Key                string
 	Name               string
+	Type               string
 	Path               string
 	Commands           []Command
 	State              State
-	cmd                *exec.Cmd
-	outPipe            *io.ReadCloser
-	errPipe            *io.ReadCloser
-	Program            *tea.Program
+	step               *step
+	inPipe             *io.WriteCloser
+	ptyFile            *os.File
+	Tty                bool
+	Stdin              bool
+	Unhealthy          bool
+	Failed             bool
+	RestartPending     bool
+	restartFrom        int
+	Program            Sender
 	StateMutex         sync.RWMutex
 	TermAttemptCount   int
-	Pid                int
+	Pids               []int
 	ActiveCommandIndex int
 	Configuration      *config.Config
 	Log                *log.Log
 	Healthcheck        Healthcheck
 	WaitList           []string
 	Touched            bool
+	RestartPolicy      RestartPolicy
+	RestartCount       int
+	NextRestart        time.Time
+	restartTimer       *time.Timer
+	restarting         bool
+	healthcheckFailure bool
+	startedAt          time.Time
+	StopTimeout        config.StopTimeout
+	ResendAt           time.Time
+	KillAt             time.Time
+	stopTimers         []*time.Timer
+	StopSignal         string
+	StopCommand        string
+	Env                map[string]string
+	EnvFile            []string
+	ContextEnv         env.Source
+	Cascade            Cascade
+	StopWaitList       []string
+	ResumeAfter        []string
+	Watch              *Watch
+	Hooks              Hooks
+	hookCancel         context.CancelFunc
+	preStartDone       bool
+	Usage              *Usage
+	usagePids          []int
+	cpuTime            time.Duration
+	usageSampledAt     time.Time
 }
 
 func (s *Service) GetState() State {
@@ -59,16 +121,61 @@ func (s *Service) GetState() State {
 
 var Services map[string]*Service
 
-func New(key string, name string, path string, commands []Command, configuration *config.Config, healthcheck Healthcheck) Service {
-	return Service{
+// Sender receives the messages of services, it's the bubbletea program unless running headless
+type Sender interface {
+	Send(msg tea.Msg)
+}
+
+type Definition struct {
+	Name        string
+	Path        string
+	Commands    []Command
+	Healthcheck Healthcheck
+	Restart     RestartPolicy
+	StopTimeout config.StopTimeout `yaml:"stopTimeout"`
+	StopSignal  string             `yaml:"stopSignal"`
+	StopCommand string             `yaml:"stopCommand"`
+	Env         map[string]string  `yaml:"env"`
+	EnvFile     []string           `yaml:"envFile"`
+	Tty         bool               `yaml:"tty"`
+	Stdin       bool               `yaml:"stdin"`
+	Cascade     Cascade            `yaml:"cascade"`
+	Watch       *Watch             `yaml:"watch"`
+	Hooks       Hooks              `yaml:"hooks"`
+	Type        string             `yaml:"type"`
+	Autostart   bool               `yaml:"autostart"`
+}
+
+func New(key string, name string, path string, definition Definition, configuration *config.Config, contextEnv env.Source) *Service {
+	s := &Service{
 		Key:           key,
 		Name:          name,
+		Type:          definition.Type,
 		Path:          path,
-		Commands:      commands,
+		Commands:      definition.Commands,
 		Configuration: configuration,
 		Log:           log.New(configuration),
-		Healthcheck:   healthcheck,
+		Healthcheck:   definition.Healthcheck,
+		RestartPolicy: definition.Restart,
+		StopTimeout:   definition.StopTimeout,
+		StopSignal:    definition.StopSignal,
+		StopCommand:   definition.StopCommand,
+		Env:           definition.Env,
+		EnvFile:       definition.EnvFile,
+		ContextEnv:    contextEnv,
+		Tty:           definition.Tty,
+		Stdin:         definition.Stdin,
+		Cascade:       definition.Cascade,
+		Watch:         definition.Watch,
+		Hooks:         definition.Hooks,
+	}
+	if s.Type == "" {
+		s.Type = TypeService
 	}
+	if s.Tty || s.Stdin {
+		s.Log.SetStdinHandler(s.writeStdin)
+	}
+	return s
 }
 
 type ServiceStoppedMsg struct {
@@ -87,6 +194,18 @@ type StartServiceMsg struct {
 	Service string
 }
 
+type StopServiceMsg struct {
+	Service string
+}
+
+type RestartServiceMsg struct {
+	Service string
+}
+
+type RestartBackoffMsg struct {
+	Service string
+}
+
 type ContentUpdateMsg struct{}
 
 func (s *Service) addOutput(addition string, endLine bool, lineType LineType) {
@@ -109,15 +228,48 @@ func (s *Service) addSysoutLine(addition string) {
 	s.addOutput(addition, true, LineTypeSysout)
 }
 
-func writeFromPipe(pipe *io.ReadCloser, isErrorPipe bool, s *Service, wg *sync.WaitGroup) {
+func (s *Service) writeStdin(input string) error {
+	s.StateMutex.RLock()
+	inPipe := s.inPipe
+	tty := s.ptyFile != nil
+	parallel := s.step != nil && s.step.parallel()
+	s.StateMutex.RUnlock()
+	if inPipe == nil && parallel {
+		return errors.New("input is not supported for parallel commands")
+	}
+	if inPipe == nil {
+		return errors.New("service is not running")
+	}
+	if tty {
+		input = strings.ReplaceAll(input, "\n", "\r")
+	}
+	_, err := io.WriteString(*inPipe, input)
+	return err
+}
+
+func (s *Service) SetTtySize(width int, height int) {
+	s.StateMutex.RLock()
+	ptyFile := s.ptyFile
+	s.StateMutex.RUnlock()
+	if ptyFile == nil {
+		return
+	}
+	if err := sys.ResizePty(ptyFile, width, height); err != nil {
+		s.addSyserrLine(fmt.Sprintf("Error resizing tty: %s", err))
+	}
+}
+
+func writeFromPipe(pipe *io.ReadCloser, isErrorPipe bool, prefix string, s *Service, wg *sync.WaitGroup) {
 	buf := bufio.NewReader(*pipe)
+	lineStart := true
 	for {
 		line, isPrefix, err := buf.ReadLine()
 		if err == io.EOF {
 			wg.Done()
 			return
 		} else if err != nil {
-			if err.Error() != "read |0: file already closed" {
+			// reading from a tty whose process has exited fails with EIO instead of EOF
+			if err.Error() != "read |0: file already closed" && !errors.Is(err, syscall.EIO) {
 				bufname := "stdout"
 				if isErrorPipe {
 					bufname = "stderr"
@@ -127,10 +279,15 @@ func writeFromPipe(pipe *io.ReadCloser, isErrorPipe bool, s *Service, wg *sync.W
 			wg.Done()
 			return
 		} else {
+			addition := string(line)
+			if lineStart {
+				addition = prefix + addition
+			}
+			lineStart = !isPrefix
 			if isErrorPipe {
-				s.addSterr(string(line), !isPrefix)
+				s.addSterr(addition, !isPrefix)
 			} else {
-				s.addStdout(string(line), !isPrefix)
+				s.addStdout(addition, !isPrefix)
 			}
 		}
 	}
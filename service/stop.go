@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andresrobam/leggo/config"
+	"github.com/andresrobam/leggo/sys"
+)
+
+func (s *Service) stopTimeout() config.StopTimeout {
+	return s.Configuration.StopTimeout.Override(s.StopTimeout)
+}
+
+func (s *Service) scheduleStopEscalation() {
+	st := s.step
+	timeout := s.stopTimeout()
+	now := time.Now()
+	if timeout.Resend > 0 {
+		s.ResendAt = now.Add(time.Duration(timeout.Resend) * time.Second)
+		s.stopTimers = append(s.stopTimers, time.AfterFunc(time.Until(s.ResendAt), func() {
+			s.StateMutex.Lock()
+			defer s.StateMutex.Unlock()
+			if s.step != st || s.State != StateStopping {
+				return
+			}
+			s.ResendAt = time.Time{}
+			s.addSysoutLine(fmt.Sprintf("Process still running after %ds, sending stop signal again", timeout.Resend))
+			if err := s.sendStopSignal(st); err != nil {
+				s.addSyserrLine(fmt.Sprintf("Error closing process: %s", err))
+			}
+		}))
+	}
+	if timeout.Kill > 0 {
+		s.KillAt = now.Add(time.Duration(timeout.Kill) * time.Second)
+		s.stopTimers = append(s.stopTimers, time.AfterFunc(time.Until(s.KillAt), func() {
+			s.StateMutex.Lock()
+			defer s.StateMutex.Unlock()
+			if s.step != st || s.State != StateStopping {
+				return
+			}
+			s.KillAt = time.Time{}
+			s.addSysoutLine(fmt.Sprintf("Process still running after %ds, killing process", timeout.Kill))
+			if err := st.signal(sys.Kill); err != nil {
+				s.addSyserrLine(fmt.Sprintf("Error killing process: %s", err))
+			}
+		}))
+	}
+}
+
+func (s *Service) cancelStopEscalation() {
+	for _, timer := range s.stopTimers {
+		timer.Stop()
+	}
+	s.stopTimers = nil
+	s.ResendAt = time.Time{}
+	s.KillAt = time.Time{}
+}
+
+func (s *Service) StopCountdown() string {
+	if !s.KillAt.IsZero() {
+		return fmt.Sprintf("kill in %s", time.Until(s.KillAt).Round(time.Second))
+	}
+	if !s.ResendAt.IsZero() {
+		return fmt.Sprintf("resend in %s", time.Until(s.ResendAt).Round(time.Second))
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/andresrobam/leggo/sys"
+)
+
+// Usage of the processes of a service, Cpu is only known from the second sample of the same processes on
+type Usage struct {
+	Rss        int64
+	Cpu        float64
+	CpuSampled bool
+}
+
+// UpdateUsage sums up the usage of the process groups of the service,
+// the cpu usage is averaged over the time since the previous update
+func (s *Service) UpdateUsage(groups map[int][]sys.Process, now time.Time) {
+	s.StateMutex.Lock()
+	defer s.StateMutex.Unlock()
+	if len(s.Pids) == 0 {
+		s.Usage = nil
+		s.usagePids = nil
+		return
+	}
+	var usage Usage
+	var cpuTime time.Duration
+	for _, pid := range s.Pids {
+		for _, process := range groups[pid] {
+			usage.Rss += process.Rss
+			cpuTime += process.CpuTime
+		}
+	}
+	if slices.Equal(s.usagePids, s.Pids) && cpuTime >= s.cpuTime {
+		usage.Cpu = float64(cpuTime-s.cpuTime) / float64(now.Sub(s.usageSampledAt)) * 100
+		usage.CpuSampled = true
+	}
+	s.usagePids = s.Pids
+	s.cpuTime = cpuTime
+	s.usageSampledAt = now
+	s.Usage = &usage
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch restarts the service when files matching the include globs change,
+// globs are relative to the service path and a glob without a slash matches in any directory
+type Watch struct {
+	Include     []string `yaml:"include"`
+	Exclude     []string `yaml:"exclude"`
+	Debounce    int      `yaml:"debounce"`
+	FromCommand int      `yaml:"fromCommand"`
+}
+
+const defaultWatchDebounce = 500
+
+var alwaysExcluded = []string{".git/**"}
+
+type FileChangedMsg struct {
+	Service string
+	Files   []string
+}
+
+type watchMatcher struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// globRegexp compiles the glob, withContents also matches everything under the matched directories
+func globRegexp(glob string, withContents bool) *regexp.Regexp {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+	var expression strings.Builder
+	expression.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		expression.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expression.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			expression.WriteString("[^/]*")
+		case glob[i] == '?':
+			expression.WriteString("[^/]")
+		default:
+			expression.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if withContents {
+		expression.WriteString("(/.*)?")
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+func newWatchMatcher(w *Watch) watchMatcher {
+	var m watchMatcher
+	include := w.Include
+	if len(include) == 0 {
+		include = []string{"**"}
+	}
+	for _, glob := range include {
+		m.include = append(m.include, globRegexp(glob, false))
+	}
+	for _, glob := range slices.Concat(alwaysExcluded, w.Exclude) {
+		// an exclude without wildcards like node_modules names a directory or file to skip entirely
+		literal := !strings.ContainsAny(glob, "*?")
+		if literal {
+			glob = strings.TrimSuffix(glob, "/")
+		}
+		m.exclude = append(m.exclude, globRegexp(glob, literal))
+	}
+	return m
+}
+
+func matchesAny(expressions []*regexp.Regexp, path string) bool {
+	return slices.ContainsFunc(expressions, func(expression *regexp.Regexp) bool {
+		return expression.MatchString(path)
+	})
+}
+
+func (m watchMatcher) matches(path string) bool {
+	return matchesAny(m.include, path) && !matchesAny(m.exclude, path)
+}
+
+func (m watchMatcher) excludesDir(path string) bool {
+	return path != "." && matchesAny(m.exclude, path+"/")
+}
+
+func (s *Service) WatchFiles() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.addSyserrLine(fmt.Sprintf("Error watching files: %s", err))
+		return
+	}
+	defer watcher.Close()
+
+	matcher := newWatchMatcher(s.Watch)
+	s.addWatchDirs(watcher, matcher, s.Path)
+
+	debounce := s.Watch.Debounce
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+	var changed []string
+	var debounceTimer <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					s.addWatchDirs(watcher, matcher, event.Name)
+				}
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			relativePath, err := filepath.Rel(s.Path, event.Name)
+			if err != nil {
+				continue
+			}
+			relativePath = filepath.ToSlash(relativePath)
+			if !matcher.matches(relativePath) {
+				continue
+			}
+			if !slices.Contains(changed, relativePath) {
+				changed = append(changed, relativePath)
+			}
+			debounceTimer = time.After(time.Duration(debounce) * time.Millisecond)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.addSyserrLine(fmt.Sprintf("Error watching files: %s", err))
+		case <-debounceTimer:
+			s.Program.Send(FileChangedMsg{Service: s.Key, Files: changed})
+			changed = nil
+			debounceTimer = nil
+		}
+	}
+}
+
+func (s *Service) addWatchDirs(watcher *fsnotify.Watcher, matcher watchMatcher, dir string) {
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(s.Path, path)
+		if err != nil {
+			return nil
+		}
+		if matcher.excludesDir(filepath.ToSlash(relativePath)) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			s.addSyserrLine(fmt.Sprintf("Error watching %s: %s", path, err))
+		}
+		return nil
+	})
+}
+
+func (s *Service) RestartForChange(files []string) {
+	if s.State != StateStarting && s.State != StateRunning && s.State != StateDone {
+		return
+	}
+	changed := files[0]
+	if len(files) > 1 {
+		changed += fmt.Sprintf(" and %d more", len(files)-1)
+	}
+	if s.State == StateDone {
+		s.addSysoutLine(fmt.Sprintf("File changed: %s, rerunning task", changed))
+		s.StartService()
+		return
+	}
+	fromCommand := s.Watch.FromCommand
+	if s.ActiveCommandIndex < fromCommand {
+		fromCommand = 0
+	}
+	if fromCommand > 0 {
+		s.addSysoutLine(fmt.Sprintf("File changed: %s, rerunning from command %d", changed, fromCommand))
+	} else {
+		s.addSysoutLine(fmt.Sprintf("File changed: %s, restarting", changed))
+	}
+	s.restartFrom = fromCommand
+	s.Restart()
+}
@@ -0,0 +1,25 @@
+package sys
+
+import "time"
+
+type Process struct {
+	Pid       int
+	Ppid      int
+	Pgid      int
+	Rss       int64
+	CpuTime   time.Duration
+	StartTime time.Time
+}
+
+// ProcessesByGroup groups every running process by its process group id
+func ProcessesByGroup() (map[int][]Process, error) {
+	processes, err := Processes()
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[int][]Process)
+	for _, process := range processes {
+		groups[process.Pgid] = append(groups[process.Pgid], process)
+	}
+	return groups, nil
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the kernel reports cpu times in USER_HZ which is 100 on every supported architecture
+const clockTicks = 100
+
+var bootTime = sync.OnceValue(func() time.Time {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for line := range strings.Lines(string(stat)) {
+		if btime, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, _ := strconv.ParseInt(strings.TrimSpace(btime), 10, 64)
+			return time.Unix(seconds, 0)
+		}
+	}
+	return time.Time{}
+})
+
+// Processes reads every running process from /proc
+func Processes() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var processes []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		process, err := readProcess(pid)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+func readProcess(pid int) (Process, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return Process{}, err
+	}
+	// the command name can contain spaces and parentheses, the fields start after the last parenthesis
+	commEnd := strings.LastIndexByte(string(stat), ')')
+	if commEnd == -1 {
+		return Process{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat[commEnd+1:]))
+	if len(fields) < 22 {
+		return Process{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	pgid, _ := strconv.Atoi(fields[2])
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	startTime, _ := strconv.ParseInt(fields[19], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	return Process{
+		Pid:       pid,
+		Ppid:      ppid,
+		Pgid:      pgid,
+		Rss:       rss * int64(os.Getpagesize()),
+		CpuTime:   time.Duration(utime+stime) * time.Second / clockTicks,
+		StartTime: bootTime().Add(time.Duration(startTime) * time.Second / clockTicks),
+	}, nil
+}
+
+// CommandLine returns the arguments of the process joined by spaces,
+// or the name of the executable in brackets for kernel threads and zombies
+func CommandLine(pid int) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err == nil && len(cmdline) != 0 {
+		return string(bytes.ReplaceAll(bytes.TrimRight(cmdline, "\x00"), []byte{0}, []byte{' '}))
+	}
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return "[" + strings.TrimSpace(string(comm)) + "]"
+}
+
+// FindProcess reads a single process from /proc
+func FindProcess(pid int) (Process, error) {
+	return readProcess(pid)
+}
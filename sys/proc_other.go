@@ -0,0 +1,17 @@
+//go:build !linux
+
+package sys
+
+import "errors"
+
+func Processes() ([]Process, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func CommandLine(pid int) string {
+	return ""
+}
+
+func FindProcess(pid int) (Process, error) {
+	return Process{}, errors.ErrUnsupported
+}
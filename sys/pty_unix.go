@@ -0,0 +1,23 @@
+//go:build unix
+
+package sys
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+func StartPty(cmd *exec.Cmd, width int, height int) (*os.File, error) {
+	return pty.StartWithAttrs(cmd, ptySize(width, height), &syscall.SysProcAttr{Setsid: true, Setctty: true})
+}
+
+func ResizePty(ptyFile *os.File, width int, height int) error {
+	return pty.Setsize(ptyFile, ptySize(width, height))
+}
+
+func ptySize(width int, height int) *pty.Winsize {
+	return &pty.Winsize{Cols: uint16(max(width, 1)), Rows: uint16(max(height, 1))}
+}
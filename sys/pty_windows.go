@@ -0,0 +1,19 @@
+//go:build windows
+
+package sys
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errPtyUnsupported = errors.New("tty is not supported on windows")
+
+func StartPty(cmd *exec.Cmd, width int, height int) (*os.File, error) {
+	return nil, errPtyUnsupported
+}
+
+func ResizePty(ptyFile *os.File, width int, height int) error {
+	return errPtyUnsupported
+}
@@ -3,10 +3,39 @@
 package sys
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"syscall"
 )
 
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal, ok := signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %s", name)
+	}
+	return signal, nil
+}
+
+func ValidSignal(name string) bool {
+	_, err := parseSignal(name)
+	return err == nil
+}
+
 func GetSysProcAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{Setpgid: true}
 }
@@ -15,6 +44,17 @@ func GracefulStop(process *os.Process) error {
 	return syscall.Kill(-process.Pid, syscall.SIGTERM)
 }
 
+func StopSignal(process *os.Process, signal string) error {
+	if signal == "" {
+		return GracefulStop(process)
+	}
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return err
+	}
+	return syscall.Kill(-process.Pid, sig)
+}
+
 func Kill(process *os.Process) error {
 	return syscall.Kill(-process.Pid, syscall.SIGKILL)
 }
@@ -22,3 +62,12 @@ func Kill(process *os.Process) error {
 func ShouldKillMatchingRegex() []string {
 	return []string{}
 }
+
+// SignalProcess sends the signal to a single process instead of its whole process group
+func SignalProcess(pid int, signal string) error {
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return err
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -3,12 +3,25 @@
 package sys
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
+	"strings"
 	"syscall"
 )
 
+var signals = []string{"SIGHUP", "SIGINT", "SIGQUIT", "SIGKILL", "SIGUSR1", "SIGUSR2", "SIGTERM"}
+
+func ValidSignal(name string) bool {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return slices.Contains(signals, name)
+}
+
 func GetSysProcAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{HideWindow: true, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
 }
@@ -29,6 +42,14 @@ func GracefulStop(process *os.Process) error {
 	return nil
 }
 
+// console processes can only be sent ctrl+break as a group, so the signal is only honored for kill
+func StopSignal(process *os.Process, signal string) error {
+	if strings.EqualFold(signal, "SIGKILL") || strings.EqualFold(signal, "KILL") {
+		return Kill(process)
+	}
+	return GracefulStop(process)
+}
+
 func Kill(process *os.Process) error {
 	return exec.Command("taskkill", "/t", "/f", "/pid", strconv.Itoa(process.Pid)).Run()
 }
@@ -36,3 +57,11 @@ func Kill(process *os.Process) error {
 func ShouldKillMatchingRegex() []string {
 	return []string{"^(\\.|\\.\\/)?(gradle|mvn)w?.*", "^javaw? .*"}
 }
+
+// SignalProcess can only kill a single process, windows has no other signals to send
+func SignalProcess(pid int, signal string) error {
+	if strings.EqualFold(signal, "SIGKILL") || strings.EqualFold(signal, "KILL") {
+		return exec.Command("taskkill", "/f", "/pid", strconv.Itoa(pid)).Run()
+	}
+	return fmt.Errorf("sending %s is not supported on windows", signal)
+}
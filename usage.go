@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andresrobam/leggo/service"
+	"github.com/andresrobam/leggo/sys"
+)
+
+const usageSampleInterval = 2 * time.Second
+
+// sampleUsage keeps the usage of the services up to date, it stops right away where /proc isn't available
+func sampleUsage(services []*service.Service) {
+	ticker := time.NewTicker(usageSampleInterval)
+	defer ticker.Stop()
+	for {
+		groups, err := sys.ProcessesByGroup()
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		for _, s := range services {
+			s.UpdateUsage(groups, now)
+		}
+		<-ticker.C
+	}
+}
+
+var usagePopupContent string
+
+func formatUsage(usage *service.Usage) string {
+	if !usage.CpuSampled {
+		return "RSS " + formatDataSize(int(usage.Rss))
+	}
+	return fmt.Sprintf("RSS %s, CPU %.0f%%", formatDataSize(int(usage.Rss)), usage.Cpu)
+}
+
+func formatCpu(usage service.Usage) string {
+	if !usage.CpuSampled {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", usage.Cpu)
+}
+
+func openUsagePopup() {
+	usagePopupContent = usagePopup()
+	popup = usagePopupContent
+}
+
+// refreshUsagePopup updates the usage popup while it's the one being shown
+func refreshUsagePopup() {
+	if popup != "" && popup == usagePopupContent {
+		openUsagePopup()
+	}
+}
+
+func usagePopup() string {
+	type serviceUsage struct {
+		name  string
+		usage service.Usage
+	}
+	var usages []serviceUsage
+	width := len("Total")
+	for _, s := range services {
+		s.StateMutex.RLock()
+		if s.Usage != nil {
+			usages = append(usages, serviceUsage{s.Name, *s.Usage})
+			width = max(width, len(s.Name))
+		}
+		s.StateMutex.RUnlock()
+	}
+	if len(usages) == 0 {
+		return "No usage of running services available"
+	}
+	slices.SortStableFunc(usages, func(a, b serviceUsage) int {
+		return cmp.Compare(b.usage.Rss, a.usage.Rss)
+	})
+	lines := []string{"Usage by memory:", ""}
+	var total int64
+	for _, u := range usages {
+		lines = append(lines, fmt.Sprintf("%-*s  %8s  %5s", width, u.name, formatDataSize(int(u.usage.Rss)), formatCpu(u.usage)))
+		total += u.usage.Rss
+	}
+	lines = append(lines, "", fmt.Sprintf("%-*s  %8s", width, "Total", formatDataSize(int(total))))
+	return strings.Join(lines, "\n")
+}
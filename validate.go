@@ -0,0 +1,285 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/andresrobam/leggo/service"
+	"github.com/andresrobam/leggo/sys"
+	"github.com/andresrobam/leggo/yaml"
+)
+
+type contextValidator struct {
+	document    *yaml.Document
+	definition  *contextDefinition
+	serviceKeys []string
+	groupKeys   []string
+	contextDir  string
+	diagnostics []yaml.Diagnostic
+}
+
+func runValidate(args []string) int {
+	if len(args) < 1 {
+		fmt.Println("No file name provided.")
+		return 1
+	}
+	var invalid bool
+	for _, fileName := range args {
+		ymlData, err := os.ReadFile(fileName)
+		if err != nil {
+			fmt.Println("Error opening file: ", err)
+			invalid = true
+			continue
+		}
+		problems := validateContext(fileName, ymlData)
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) != 0 {
+			invalid = true
+		} else {
+			fmt.Printf("%s: no problems found\n", fileName)
+		}
+	}
+	if invalid {
+		return 1
+	}
+	return 0
+}
+
+func validateContext(fileName string, ymlData []byte) []string {
+	document, err := yaml.Parse(ymlData)
+	if err != nil {
+		return []string{formatDiagnostic(fileName, yaml.ErrorDiagnostic(err))}
+	}
+	var definition contextDefinition
+	v := contextValidator{
+		document:    document,
+		definition:  &definition,
+		diagnostics: document.Check(&definition),
+	}
+	if err := document.Decode(&definition); err != nil {
+		// type errors found by the check already cover the decoding error
+		if len(v.diagnostics) == 0 {
+			v.diagnostics = append(v.diagnostics, yaml.ErrorDiagnostic(err))
+		}
+	} else {
+		absoluteFilePath, _ := filepath.Abs(fileName)
+		v.contextDir = filepath.Dir(absoluteFilePath)
+		v.serviceKeys, _ = yaml.GetKeys(ymlData, "$.services")
+		v.groupKeys, _ = yaml.GetKeys(ymlData, "$.groups")
+		v.validate()
+	}
+
+	slices.SortStableFunc(v.diagnostics, func(a yaml.Diagnostic, b yaml.Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	problems := make([]string, 0, len(v.diagnostics))
+	for _, diagnostic := range slices.Compact(v.diagnostics) {
+		problems = append(problems, formatDiagnostic(fileName, diagnostic))
+	}
+	return problems
+}
+
+func formatDiagnostic(fileName string, diagnostic yaml.Diagnostic) string {
+	if diagnostic.Line == 0 {
+		return fmt.Sprintf("%s: %s", fileName, diagnostic.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", fileName, diagnostic.Line, diagnostic.Column, diagnostic.Message)
+}
+
+func (v *contextValidator) report(message string, path ...any) {
+	v.diagnostics = append(v.diagnostics, v.document.Diagnostic(message, path...))
+}
+
+func (v *contextValidator) validate() {
+	if len(v.definition.Services) == 0 {
+		v.report("no services defined, must define at least 1 service", "services")
+	}
+	v.validateEnvFiles(v.contextDir, v.definition.EnvFile, "envFile")
+
+	for _, key := range v.serviceKeys {
+		if !v.document.Invalid("services", key) {
+			v.validateService(key, v.definition.Services[key])
+		}
+	}
+	v.validateDependencyCycles()
+	v.validateGroups()
+}
+
+func (v *contextValidator) validateGroups() {
+	for _, groupKey := range v.groupKeys {
+		serviceKeys := v.definition.Groups[groupKey]
+		if len(serviceKeys) == 0 {
+			v.report("group has no services", "groups", groupKey)
+		}
+		for i, serviceKey := range serviceKeys {
+			if _, ok := v.definition.Services[serviceKey]; !ok {
+				v.report(fmt.Sprintf("unknown service \"%s\" in group", serviceKey), "groups", groupKey, i)
+			}
+		}
+	}
+}
+
+func (v *contextValidator) validateService(key string, s service.Definition) {
+	servicePath := resolveServicePath(v.contextDir, s.Path)
+	if s.Path != "" {
+		v.validateDirectory(servicePath, "services", key, "path")
+	}
+	v.validateEnvFiles(servicePath, s.EnvFile, "services", key, "envFile")
+
+	if len(s.Commands) == 0 && !v.document.Invalid("services", key, "commands") {
+		v.report("service has no commands", "services", key)
+	}
+	for i, c := range s.Commands {
+		if v.document.Invalid("services", key, "commands", i) {
+			continue
+		}
+		if len(c.Parallel) == 0 && strings.TrimSpace(c.Command) == "" {
+			v.report("empty command", "services", key, "commands", i)
+		}
+		if len(c.Parallel) != 0 && c.Command != "" {
+			v.report("command and parallel can't both be set", "services", key, "commands", i, "parallel")
+		}
+		if len(c.Parallel) != 0 && s.Tty {
+			v.report("tty is not supported for parallel commands", "services", key, "commands", i, "parallel")
+		}
+		if len(c.Parallel) != 0 && s.Stdin {
+			v.report("stdin is not supported for parallel commands", "services", key, "commands", i, "parallel")
+		}
+		for j, parallelCommand := range c.Parallel {
+			if strings.TrimSpace(parallelCommand.Command) == "" {
+				v.report("empty command", "services", key, "commands", i, "parallel", j)
+			}
+		}
+		commandPath := servicePath
+		if c.Path != "" {
+			commandPath = c.Path
+			if !filepath.IsAbs(commandPath) {
+				commandPath = filepath.Join(servicePath, commandPath)
+			}
+			v.validateDirectory(commandPath, "services", key, "commands", i, "path")
+		}
+		v.validateEnvFiles(commandPath, c.EnvFile, "services", key, "commands", i, "envFile")
+		for j, requiredService := range c.Requires {
+			if _, ok := v.definition.Services[requiredService]; !ok {
+				v.report(fmt.Sprintf("unknown service \"%s\" in requires", requiredService), "services", key, "commands", i, "requires", j)
+			}
+		}
+		if c.StopSignal != "" && !sys.ValidSignal(c.StopSignal) {
+			v.report(fmt.Sprintf("unknown stop signal \"%s\"", c.StopSignal), "services", key, "commands", i, "stopSignal")
+		}
+	}
+
+	switch s.Type {
+	case "", service.TypeService:
+	case service.TypeTask:
+		if s.Healthcheck.Defined() {
+			v.report("tasks don't support healthchecks, a task is done when its last command succeeds", "services", key, "healthcheck")
+		}
+	default:
+		v.report(fmt.Sprintf("unknown service type \"%s\", must be one of %s, %s", s.Type, service.TypeService, service.TypeTask), "services", key, "type")
+	}
+	if s.StopSignal != "" && !sys.ValidSignal(s.StopSignal) {
+		v.report(fmt.Sprintf("unknown stop signal \"%s\"", s.StopSignal), "services", key, "stopSignal")
+	}
+	switch s.Restart.Policy {
+	case "", service.RestartNever, service.RestartOnFailure, service.RestartAlways:
+	default:
+		v.report(fmt.Sprintf("unknown restart policy \"%s\", must be one of %s, %s, %s", s.Restart.Policy, service.RestartNever, service.RestartOnFailure, service.RestartAlways), "services", key, "restart", "policy")
+	}
+	if http := s.Healthcheck.Http; http != nil {
+		if http.Url == "" {
+			v.report("http healthcheck has no url", "services", key, "healthcheck", "http")
+		}
+		if _, err := regexp.Compile(http.Body); err != nil {
+			v.report(fmt.Sprintf("invalid body regex: %s", err), "services", key, "healthcheck", "http", "body")
+		}
+	}
+	for i, dependency := range s.Cascade.Dependencies {
+		if !slices.Contains(v.requiredServices(key), dependency) {
+			v.report(fmt.Sprintf("cascade dependency \"%s\" is not required by any command", dependency), "services", key, "cascade", "dependencies", i)
+		}
+	}
+	if s.Watch != nil && (s.Watch.FromCommand < 0 || s.Watch.FromCommand >= max(len(s.Commands), 1)) {
+		v.report(fmt.Sprintf("fromCommand %d is out of range, service has %d commands", s.Watch.FromCommand, len(s.Commands)), "services", key, "watch", "fromCommand")
+	}
+	if s.Healthcheck.Liveness != nil && !s.Healthcheck.Defined() {
+		v.report("liveness requires a healthcheck command, http, tcp or file check", "services", key, "healthcheck", "liveness")
+	}
+}
+
+func (v *contextValidator) validateDirectory(path string, yamlPath ...any) {
+	info, err := os.Stat(path)
+	if err != nil {
+		v.report(fmt.Sprintf("path %s does not exist", path), yamlPath...)
+	} else if !info.IsDir() {
+		v.report(fmt.Sprintf("path %s is not a directory", path), yamlPath...)
+	}
+}
+
+func (v *contextValidator) validateEnvFiles(dir string, envFiles []string, yamlPath ...any) {
+	for i, envFile := range envFiles {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(dir, envFile)
+		}
+		if _, err := os.Stat(envFile); err != nil {
+			v.report(fmt.Sprintf("env file %s does not exist", envFile), append(slices.Clone(yamlPath), i)...)
+		}
+	}
+}
+
+func (v *contextValidator) requiredServices(key string) []string {
+	var requiredServices []string
+	for _, c := range v.definition.Services[key].Commands {
+		for _, requiredService := range c.Requires {
+			if _, ok := v.definition.Services[requiredService]; ok && !slices.Contains(requiredServices, requiredService) {
+				requiredServices = append(requiredServices, requiredService)
+			}
+		}
+	}
+	return requiredServices
+}
+
+func (v *contextValidator) validateDependencyCycles() {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var stack []string
+	var reported []string
+
+	var visit func(key string)
+	visit = func(key string) {
+		states[key] = visiting
+		stack = append(stack, key)
+		for _, requiredService := range v.requiredServices(key) {
+			switch states[requiredService] {
+			case unvisited:
+				visit(requiredService)
+			case visiting:
+				cycle := slices.Clone(stack[slices.Index(stack, requiredService):])
+				sortedCycle := slices.Sorted(slices.Values(cycle))
+				if id := strings.Join(sortedCycle, ","); !slices.Contains(reported, id) {
+					reported = append(reported, id)
+					v.report(fmt.Sprintf("dependency cycle: %s -> %s", strings.Join(cycle, " -> "), requiredService), "services", requiredService)
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		states[key] = visited
+	}
+
+	for _, key := range v.serviceKeys {
+		if states[key] == unvisited {
+			visit(key)
+		}
+	}
+}
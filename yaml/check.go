@@ -0,0 +1,288 @@
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+type Document struct {
+	file    *ast.File
+	data    []byte
+	invalid [][]any
+}
+
+func Parse(ymlData []byte) (*Document, error) {
+	file, err := parser.ParseBytes(ymlData, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{file: file, data: ymlData}, nil
+}
+
+func ErrorDiagnostic(err error) Diagnostic {
+	if yamlErr, ok := err.(yaml.Error); ok {
+		return tokenDiagnostic(yamlErr.GetToken(), yamlErr.GetMessage())
+	}
+	return Diagnostic{Message: err.Error()}
+}
+
+// Position returns the line and column of the key or the sequence entry at the given path,
+// path elements are either map keys (string) or sequence indexes (int)
+func (d *Document) Position(path ...any) (int, int) {
+	if len(d.file.Docs) == 0 || d.file.Docs[0].Body == nil {
+		return 0, 0
+	}
+	node := d.file.Docs[0].Body
+	tk := firstToken(node)
+	for _, element := range path {
+		node = unwrap(node)
+		switch element := element.(type) {
+		case string:
+			values, _ := mappingValues(node)
+			i := slices.IndexFunc(values, func(value *ast.MappingValueNode) bool {
+				return value.Key.GetToken() != nil && value.Key.GetToken().Value == element
+			})
+			if i == -1 {
+				return 0, 0
+			}
+			node, tk = values[i].Value, values[i].Key.GetToken()
+		case int:
+			sequence, ok := node.(*ast.SequenceNode)
+			if !ok || element < 0 || element >= len(sequence.Values) {
+				return 0, 0
+			}
+			node = sequence.Values[element]
+			tk = firstToken(node)
+		}
+	}
+	if tk == nil {
+		return 0, 0
+	}
+	return tk.Position.Line, tk.Position.Column
+}
+
+// firstToken returns the first key of a mapping, a mapping's own token points at its first value
+func firstToken(node ast.Node) *token.Token {
+	node = unwrap(node)
+	if node == nil {
+		return nil
+	}
+	if values, ok := mappingValues(node); ok && len(values) != 0 {
+		return values[0].Key.GetToken()
+	}
+	return node.GetToken()
+}
+
+func (d *Document) Diagnostic(message string, path ...any) Diagnostic {
+	line, column := d.Position(path...)
+	return Diagnostic{Line: line, Column: column, Message: message}
+}
+
+// Check reports fields that don't exist in the target type and values that can't be decoded into it
+func (d *Document) Check(target any) []Diagnostic {
+	var c checker
+	for _, doc := range d.file.Docs {
+		if doc.Body != nil {
+			c.check(doc.Body, reflect.TypeOf(target))
+		}
+	}
+	return c.diagnostics
+}
+
+// Decode decodes the document into target leaving out the values that Check reports as badly typed,
+// so the rest of the document can still be validated
+func (d *Document) Decode(target any) error {
+	file, err := parser.ParseBytes(d.data, 0)
+	if err != nil {
+		return err
+	}
+	if len(file.Docs) == 0 || file.Docs[0].Body == nil {
+		return nil
+	}
+	c := checker{strip: true}
+	if !c.check(file.Docs[0].Body, reflect.TypeOf(target)) {
+		return yaml.Unmarshal(d.data, target)
+	}
+	d.invalid = c.invalid
+	return yaml.NodeToValue(file.Docs[0].Body, target)
+}
+
+// Invalid tells whether the value at the given path was left out by Decode because it is badly typed
+func (d *Document) Invalid(path ...any) bool {
+	return slices.ContainsFunc(d.invalid, func(invalid []any) bool {
+		return slices.Equal(invalid, path)
+	})
+}
+
+func tokenDiagnostic(tk *token.Token, message string) Diagnostic {
+	if tk == nil {
+		return Diagnostic{Message: message}
+	}
+	return Diagnostic{Line: tk.Position.Line, Column: tk.Position.Column, Message: message}
+}
+
+func unwrap(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+type checker struct {
+	diagnostics []Diagnostic
+	path        []any
+	invalid     [][]any
+	// strip replaces the badly typed values with null so the rest of the document can be decoded
+	strip bool
+}
+
+// check returns false when the node itself can't be decoded into the target type
+func (c *checker) check(node ast.Node, target reflect.Type) bool {
+	node = unwrap(node)
+	if node == nil {
+		return true
+	}
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	switch node.Type() {
+	case ast.NullType, ast.AliasType:
+		return true
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		mappingValues, ok := mappingValues(node)
+		if !ok {
+			return c.typeMismatch(node, "mapping")
+		}
+		fields := structFields(target)
+		for _, mappingValue := range mappingValues {
+			key := mappingValue.Key.GetToken().Value
+			if key == "<<" {
+				continue
+			}
+			field, ok := fields[key]
+			if !ok {
+				c.diagnostics = append(c.diagnostics, tokenDiagnostic(mappingValue.Key.GetToken(), fmt.Sprintf("unknown field \"%s\"", key)))
+				continue
+			}
+			c.checkMappingValue(mappingValue, key, field.Type)
+		}
+	case reflect.Map:
+		mappingValues, ok := mappingValues(node)
+		if !ok {
+			return c.typeMismatch(node, "mapping")
+		}
+		for _, mappingValue := range mappingValues {
+			c.checkMappingValue(mappingValue, mappingValue.Key.GetToken().Value, target.Elem())
+		}
+	case reflect.Slice:
+		sequence, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return c.typeMismatch(node, "list")
+		}
+		for i, value := range sequence.Values {
+			c.path = append(c.path, i)
+			if !c.check(value, target.Elem()) && c.strip {
+				sequence.Values[i] = ast.Null(value.GetToken())
+			}
+			c.path = c.path[:len(c.path)-1]
+		}
+	case reflect.Bool:
+		if node.Type() != ast.BoolType {
+			return c.typeMismatch(node, "boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if node.Type() != ast.IntegerType {
+			return c.typeMismatch(node, "integer")
+		}
+	case reflect.String:
+		if _, ok := node.(ast.ScalarNode); !ok {
+			return c.typeMismatch(node, "string")
+		}
+	}
+	return true
+}
+
+func (c *checker) checkMappingValue(mappingValue *ast.MappingValueNode, key string, target reflect.Type) {
+	c.path = append(c.path, key)
+	if !c.check(mappingValue.Value, target) && c.strip {
+		mappingValue.Value = ast.Null(mappingValue.Value.GetToken())
+	}
+	c.path = c.path[:len(c.path)-1]
+}
+
+func (c *checker) typeMismatch(node ast.Node, expected string) bool {
+	c.diagnostics = append(c.diagnostics, typeDiagnostic(node, expected))
+	c.invalid = append(c.invalid, slices.Clone(c.path))
+	return false
+}
+
+func typeDiagnostic(node ast.Node, expected string) Diagnostic {
+	return tokenDiagnostic(node.GetToken(), fmt.Sprintf("expected %s, got %s", expected, nodeTypeName(node)))
+}
+
+func nodeTypeName(node ast.Node) string {
+	switch node.Type() {
+	case ast.MappingType, ast.MappingValueType:
+		return "mapping"
+	case ast.SequenceType:
+		return "list"
+	case ast.BoolType:
+		return "boolean"
+	case ast.IntegerType:
+		return "integer"
+	case ast.FloatType:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func mappingValues(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}, true
+	}
+	return nil, false
+}
+
+func structFields(target reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := range target.NumField() {
+		field := target.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields[name] = field
+	}
+	return fields
+}